@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+// buildBreachIndex implements "sentinel build-breach-index": it converts a Have I Been Pwned
+// SHA-1 dump (ordered by hash) into the compact index loaded through BREACH_CORPUS_FILE.
+func buildBreachIndex(args []string) {
+	fs := flag.NewFlagSet("build-breach-index", flag.ExitOnError)
+	in := fs.String("in", "", "HIBP SHA-1 dump ordered by hash (SHA1:COUNT per line)")
+	out := fs.String("out", "breach.idx", "index file to write")
+	minCount := fs.Int("min-count", 1, "skip hashes seen fewer times than this")
+	_ = fs.Parse(args)
+
+	if *in == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	src, err := os.Open(*in)
+	if err != nil {
+		log.Fatalf("Critical: %v", err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Critical: %v", err)
+	}
+
+	n, err := security.BuildBreachIndex(src, dst, *minCount)
+	if err != nil {
+		dst.Close()
+		os.Remove(*out)
+		log.Fatalf("Critical: failed to build breach index: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		log.Fatalf("Critical: %v", err)
+	}
+
+	fmt.Printf("Wrote %d hashes to %s\n", n, *out)
+}
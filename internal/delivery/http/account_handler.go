@@ -0,0 +1,75 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// AccountHandler lets the authenticated user manage their own account.
+type AccountHandler struct {
+	usecase *usecase.AuthUsecase
+}
+
+// NewAccountHandler registers the account routes.
+// The group must be protected by JWTMiddleware.
+func NewAccountHandler(e *echo.Group, u *usecase.AuthUsecase) {
+	handler := &AccountHandler{usecase: u}
+
+	e.POST("/me/password", handler.ChangePassword)
+}
+
+// changePasswordRequest re-authenticates the user before the password is replaced.
+// Code is required only when MFA is enabled.
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+	Code            string `json:"code"`
+}
+
+// ChangePassword replaces the caller's password and returns a fresh token pair;
+// every other session is signed out.
+func (h *AccountHandler) ChangePassword(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
+	var req changePasswordRequest
+	if err := c.Bind(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	resp, err := h.usecase.ChangePassword(ctx, userID, req.CurrentPassword, req.NewPassword, req.Code, c.RealIP())
+	if err != nil {
+		var throttle *usecase.ThrottleError
+		if errors.As(err, &throttle) {
+			return throttleResponse(c, throttle)
+		}
+		var busy *usecase.BusyError
+		if errors.As(err, &busy) {
+			return busyResponse(c, busy)
+		}
+		var policy *usecase.PasswordPolicyError
+		if errors.As(err, &policy) {
+			return passwordPolicyResponse(c, policy)
+		}
+
+		switch err {
+		case usecase.ErrUserNotFound:
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		case usecase.ErrInvalidCredentials, usecase.ErrInvalidMFACode:
+			return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+		case usecase.ErrPasswordReused:
+			return c.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+		case usecase.ErrPasswordChanged:
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
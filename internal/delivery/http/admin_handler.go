@@ -0,0 +1,76 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/usecase"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminHandler exposes account administration endpoints.
+type AdminHandler struct {
+	usecase *usecase.AuthUsecase
+}
+
+// NewAdminHandler registers the admin routes.
+// The group must be protected by JWTMiddleware and RoleMiddleware("admin").
+func NewAdminHandler(e *echo.Group, u *usecase.AuthUsecase) {
+	handler := &AdminHandler{usecase: u}
+
+	e.POST("/users/:id/logout", handler.LogoutUser)
+	e.POST("/users/:id/unlock", handler.UnlockUser)
+	e.GET("/metrics/password-hashes", handler.PasswordHashMetrics)
+	e.GET("/metrics/hash-pool", handler.HashPoolMetrics)
+}
+
+// LogoutUser signs the target user out of every session.
+func (h *AdminHandler) LogoutUser(c echo.Context) error {
+	adminID, _ := c.Get("user_id").(string)
+
+	ctx := c.Request().Context()
+	if err := h.usecase.AdminLogoutUser(ctx, adminID, c.Param("id")); err != nil {
+		if err == usecase.ErrUserNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UnlockUser lifts a brute-force lockout on the target account.
+func (h *AdminHandler) UnlockUser(c echo.Context) error {
+	adminID, _ := c.Get("user_id").(string)
+
+	ctx := c.Request().Context()
+	if err := h.usecase.UnlockAccount(ctx, adminID, c.Param("id")); err != nil {
+		if err == usecase.ErrUserNotFound {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PasswordHashMetrics reports how many accounts use each password hash scheme,
+// to follow the migration of imported legacy hashes to Argon2id.
+func (h *AdminHandler) PasswordHashMetrics(c echo.Context) error {
+	ctx := c.Request().Context()
+	schemes, legacy, err := h.usecase.PasswordHashStats(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"schemes": schemes,
+		"legacy":  legacy,
+	})
+}
+
+// HashPoolMetrics reports the password hashing pool of this instance: slots in use,
+// queue depth, time spent waiting for a slot and requests shed with 503.
+func (h *AdminHandler) HashPoolMetrics(c echo.Context) error {
+	return c.JSON(http.StatusOK, security.DefaultHashPool.Stats())
+}
@@ -1,10 +1,13 @@
 package http
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
-	"github.com/labstack/echo/v4"
 	"github.com/FilipeAphrody/sentinel-auth/internal/usecase"
+	"github.com/labstack/echo/v4"
 )
 
 // AuthHandler represents the HTTP delivery layer for authentication.
@@ -16,8 +19,47 @@ type AuthHandler struct {
 func NewAuthHandler(e *echo.Group, u *usecase.AuthUsecase) {
 	handler := &AuthHandler{usecase: u}
 
+	e.POST("/register", handler.Register)
+	e.POST("/register/verify", handler.VerifyEmail)
+	e.POST("/register/resend", handler.ResendVerification)
 	e.POST("/login", handler.Login)
 	e.POST("/mfa/verify", handler.VerifyMFA)
+	e.POST("/token/refresh", handler.Refresh)
+	e.POST("/logout", handler.Logout)
+	e.POST("/password/forgot", handler.ForgotPassword)
+	e.POST("/password/reset", handler.ResetPassword)
+}
+
+// registerRequest defines the expected JSON payload for the registration endpoint.
+type registerRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// verifyEmailRequest carries the token sent to the user's inbox.
+type verifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// refreshRequest carries the opaque refresh token to be rotated.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// resendVerificationRequest identifies the account that needs a new verification email.
+type resendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// forgotPasswordRequest identifies the account that needs a reset link.
+type forgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// resetPasswordRequest carries the token from the reset link and the new password.
+type resetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 // loginRequest defines the expected JSON payload for the login endpoint.
@@ -27,9 +69,122 @@ type loginRequest struct {
 }
 
 // mfaRequest defines the expected JSON payload for the MFA verification endpoint.
+// Code is a TOTP code of TOTP_DIGITS digits or a recovery code; the usecase tells them apart.
 type mfaRequest struct {
-	Email string `json:"email" validate:"required,email"`
-	Code  string `json:"code" validate:"required,len=6"`
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// Register creates a new unverified account and sends the verification email.
+// The response is identical whether or not the address is already registered.
+func (h *AuthHandler) Register(c echo.Context) error {
+	var req registerRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	err := h.usecase.Register(ctx, req.Email, req.Password, c.RealIP())
+
+	if err != nil {
+		var busy *usecase.BusyError
+		if errors.As(err, &busy) {
+			return busyResponse(c, busy)
+		}
+		var policy *usecase.PasswordPolicyError
+		if errors.As(err, &policy) {
+			return passwordPolicyResponse(c, policy)
+		}
+
+		if err == usecase.ErrInvalidEmail {
+			return c.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{"message": "verification_email_sent"})
+}
+
+// VerifyEmail redeems the single-use verification token.
+func (h *AuthHandler) VerifyEmail(c echo.Context) error {
+	var req verifyEmailRequest
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.usecase.VerifyEmail(ctx, req.Token); err != nil {
+		if err == usecase.ErrInvalidToken {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "email_verified"})
+}
+
+// ResendVerification sends a fresh verification email.
+// The response is identical whether or not the account exists.
+func (h *AuthHandler) ResendVerification(c echo.Context) error {
+	var req resendVerificationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.usecase.ResendVerification(ctx, req.Email); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"message": "verification_email_sent"})
+}
+
+// ForgotPassword emails a password reset link.
+// The response is identical whether or not the account exists.
+func (h *AuthHandler) ForgotPassword(c echo.Context) error {
+	var req forgotPasswordRequest
+	if err := c.Bind(&req); err != nil || req.Email == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.usecase.ForgotPassword(ctx, req.Email, c.RealIP()); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"message": "password_reset_email_sent"})
+}
+
+// ResetPassword sets a new password using the single-use reset token.
+func (h *AuthHandler) ResetPassword(c echo.Context) error {
+	var req resetPasswordRequest
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.usecase.ResetPassword(ctx, req.Token, req.Password, c.RealIP()); err != nil {
+		var busy *usecase.BusyError
+		if errors.As(err, &busy) {
+			return busyResponse(c, busy)
+		}
+		var policy *usecase.PasswordPolicyError
+		if errors.As(err, &policy) {
+			return passwordPolicyResponse(c, policy)
+		}
+
+		switch err {
+		case usecase.ErrPasswordReused:
+			return c.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+		case usecase.ErrPasswordChanged:
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		case usecase.ErrInvalidToken:
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "password_changed"})
 }
 
 // Login handles the initial authentication request.
@@ -40,14 +195,27 @@ func (h *AuthHandler) Login(c echo.Context) error {
 	}
 
 	ctx := c.Request().Context()
-	resp, err := h.usecase.Login(ctx, req.Email, req.Password)
+	resp, err := h.usecase.Login(ctx, req.Email, req.Password, c.RealIP())
 
 	if err != nil {
+		// Brute-force protection: locked account (423) or backing off (429)
+		var throttle *usecase.ThrottleError
+		if errors.As(err, &throttle) {
+			return throttleResponse(c, throttle)
+		}
+
+		var busy *usecase.BusyError
+		if errors.As(err, &busy) {
+			return busyResponse(c, busy)
+		}
+
 		// Handle the specific MFA required case
 		if err == usecase.ErrMFARequired {
 			return c.JSON(http.StatusAccepted, echo.Map{
-				"message": "mfa_required",
-				"email":   req.Email,
+				"message":     "mfa_required",
+				"mfa_token":   resp.MFAToken,
+				"mfa_methods": resp.MFAMethods,
+				"expires_in":  resp.ExpiresIn,
 			})
 		}
 
@@ -56,6 +224,11 @@ func (h *AuthHandler) Login(c echo.Context) error {
 			return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
 		}
 
+		// Credentials are valid but the account has not been verified yet
+		if err == usecase.ErrEmailNotVerified {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+		}
+
 		// Generic internal error
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
 	}
@@ -71,14 +244,96 @@ func (h *AuthHandler) VerifyMFA(c echo.Context) error {
 	}
 
 	ctx := c.Request().Context()
-	resp, err := h.usecase.VerifyMFA(ctx, req.Email, req.Code)
+	resp, err := h.usecase.VerifyMFA(ctx, req.MFAToken, req.Code, c.RealIP())
 
 	if err != nil {
-		if err == usecase.ErrInvalidMFACode || err == usecase.ErrInvalidCredentials {
+		var throttle *usecase.ThrottleError
+		if errors.As(err, &throttle) {
+			return throttleResponse(c, throttle)
+		}
+
+		var busy *usecase.BusyError
+		if errors.As(err, &busy) {
+			return busyResponse(c, busy)
+		}
+
+		if err == usecase.ErrInvalidMFACode || err == usecase.ErrInvalidCredentials || err == usecase.ErrInvalidToken {
 			return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
 		}
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
 	}
 
 	return c.JSON(http.StatusOK, resp)
-}
\ No newline at end of file
+}
+
+// Refresh exchanges a refresh token for a new access/refresh token pair.
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	var req refreshRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	resp, err := h.usecase.Refresh(ctx, req.RefreshToken)
+
+	if err != nil {
+		if err == usecase.ErrInvalidToken {
+			return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Logout revokes the presented refresh token.
+func (h *AuthHandler) Logout(c echo.Context) error {
+	var req refreshRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.usecase.Logout(ctx, req.RefreshToken); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// throttleResponse answers a refused attempt with 423 Locked or 429 Too Many Requests
+// and a Retry-After header in whole seconds.
+func throttleResponse(c echo.Context, throttle *usecase.ThrottleError) error {
+	retryAfter := int(math.Ceil(throttle.RetryAfter.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	status := http.StatusTooManyRequests
+	if errors.Is(throttle, usecase.ErrAccountLocked) {
+		status = http.StatusLocked
+	}
+
+	return c.JSON(status, echo.Map{
+		"error":       throttle.Error(),
+		"retry_after": retryAfter,
+	})
+}
+
+// busyResponse answers 503 Service Unavailable when password hashing is saturated,
+// with a Retry-After header in whole seconds.
+func busyResponse(c echo.Context, busy *usecase.BusyError) error {
+	retryAfter := int(math.Ceil(busy.RetryAfter.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	return c.JSON(http.StatusServiceUnavailable, echo.Map{
+		"error":       busy.Error(),
+		"retry_after": retryAfter,
+	})
+}
+
+// passwordPolicyResponse answers 422 with the code of every password rule that failed.
+func passwordPolicyResponse(c echo.Context, policy *usecase.PasswordPolicyError) error {
+	return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+		"error":      policy.Error(),
+		"violations": policy.Violations,
+	})
+}
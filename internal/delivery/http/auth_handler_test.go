@@ -0,0 +1,43 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/usecase"
+)
+
+func TestBusyResponse(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/v1/login", nil), rec)
+
+	busy := &usecase.BusyError{Err: usecase.ErrServiceBusy, RetryAfter: 1500 * time.Millisecond}
+	if err := busyResponse(c, busy); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", rec.Code)
+	}
+	// Retry-After is rounded up to whole seconds
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Retry-After = %q, want 2", got)
+	}
+
+	var body struct {
+		Error      string `json:"error"`
+		RetryAfter int    `json:"retry_after"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error != usecase.ErrServiceBusy.Error() || body.RetryAfter != 2 {
+		t.Fatalf("body = %+v", body)
+	}
+}
@@ -1,10 +1,11 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 
-	"github.com/labstack/echo/v4"
 	"github.com/FilipeAphrody/sentinel-auth/internal/usecase"
+	"github.com/labstack/echo/v4"
 )
 
 // MFAHandler handles MFA enrollment and management.
@@ -13,12 +14,18 @@ type MFAHandler struct {
 }
 
 // NewMFAHandler registers the MFA management routes.
+// The group must be protected by JWTMiddleware: the user is taken from the token.
 func NewMFAHandler(e *echo.Group, u *usecase.AuthUsecase) {
 	handler := &MFAHandler{usecase: u}
 
 	// Routes for MFA enrollment (authenticated)
 	e.POST("/mfa/setup", handler.Setup)
 	e.POST("/mfa/enable", handler.Enable)
+	e.POST("/mfa/disable", handler.Disable)
+
+	// Recovery codes (one-time backup codes)
+	e.GET("/mfa/recovery-codes", handler.RecoveryCodesStatus)
+	e.POST("/mfa/recovery-codes", handler.RegenerateRecoveryCodes)
 }
 
 // mfaSetupResponse returns the QR code URI to the frontend.
@@ -28,41 +35,140 @@ type mfaSetupResponse struct {
 }
 
 // mfaEnableRequest is used to verify the first code before enabling MFA.
+// Its length depends on TOTP_DIGITS.
 type mfaEnableRequest struct {
-	Email string `json:"email" validate:"required,email"`
-	Code  string `json:"code" validate:"required,len=6"`
+	Code string `json:"code" validate:"required"`
 }
 
-// Setup generates a new TOTP secret for the user.
-// In a production app, this would be protected by an "Initial Auth" or Session.
+// mfaRecoveryCodesResponse returns freshly generated recovery codes. They are never shown again.
+type mfaRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// mfaDisableRequest re-authenticates the user with either a TOTP/recovery code or the password.
+type mfaDisableRequest struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+// Setup generates a new pending TOTP secret for the authenticated user.
 func (h *MFAHandler) Setup(c echo.Context) error {
-	var req struct {
-		Email string `json:"email" validate:"required,email"`
-	}
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request"})
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
 	}
 
-	// This logic would call a usecase method to generate a secret, 
-	// save it as "pending" in the DB, and return the URI.
-	// For now, we return a mock response that matches the architecture.
-	resp := mfaSetupResponse{
-		Secret: "JBSWY3DPEHPK3PXP", // Mock Base32 secret
-		QRCode: "otpauth://totp/Sentinel:user@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Sentinel",
+	ctx := c.Request().Context()
+	secret, uri, err := h.usecase.BeginMFAEnrollment(ctx, userID)
+	if err != nil {
+		return mfaError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, resp)
+	return c.JSON(http.StatusOK, mfaSetupResponse{Secret: secret, QRCode: uri})
 }
 
 // Enable verifies the provided code and officially turns on MFA for the user account.
 func (h *MFAHandler) Enable(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
+	var req mfaEnableRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request"})
+	}
+
+	ctx := c.Request().Context()
+	codes, err := h.usecase.ConfirmMFAEnrollment(ctx, userID, req.Code)
+	if err != nil {
+		return mfaError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message":        "mfa_enabled_successfully",
+		"recovery_codes": codes,
+	})
+}
+
+// Disable turns MFA off after checking a current TOTP code, a recovery code or the account password.
+func (h *MFAHandler) Disable(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
+	var req mfaDisableRequest
+	if err := c.Bind(&req); err != nil || (req.Code == "" && req.Password == "") {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "code or password is required"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.usecase.DisableMFA(ctx, userID, req.Code, req.Password, c.RealIP()); err != nil {
+		return mfaError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "mfa_disabled_successfully"})
+}
+
+// RecoveryCodesStatus reports how many unused recovery codes the user has left.
+func (h *MFAHandler) RecoveryCodesStatus(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	remaining, err := h.usecase.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return mfaError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"remaining": remaining})
+}
+
+// RegenerateRecoveryCodes invalidates the current recovery codes and returns a new set.
+// A current TOTP code is required.
+func (h *MFAHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
 	var req mfaEnableRequest
-	if err := c.Bind(&req); err != nil {
+	if err := c.Bind(&req); err != nil || req.Code == "" {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request"})
 	}
 
-	// Here you would call usecase.EnableMFA(ctx, email, code)
-	// which verifies the code and updates the user.MFAEnabled field to true.
-	
-	return c.JSON(http.StatusOK, echo.Map{"message": "mfa_enabled_successfully"})
-}
\ No newline at end of file
+	ctx := c.Request().Context()
+	codes, err := h.usecase.RegenerateRecoveryCodes(ctx, userID, req.Code, c.RealIP())
+	if err != nil {
+		return mfaError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, mfaRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// mfaError maps usecase errors from the enrollment flow to HTTP responses.
+func mfaError(c echo.Context, err error) error {
+	var throttle *usecase.ThrottleError
+	if errors.As(err, &throttle) {
+		return throttleResponse(c, throttle)
+	}
+	var busy *usecase.BusyError
+	if errors.As(err, &busy) {
+		return busyResponse(c, busy)
+	}
+
+	switch err {
+	case usecase.ErrUserNotFound:
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case usecase.ErrMFAAlreadyEnabled, usecase.ErrMFANotEnabled, usecase.ErrMFANotPending:
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	case usecase.ErrInvalidMFACode:
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	case usecase.ErrInvalidCredentials:
+		return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+}
@@ -4,13 +4,14 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+	"github.com/FilipeAphrody/sentinel-auth/internal/usecase"
 	"github.com/labstack/echo/v4"
 )
 
 // JWTMiddleware intercepts the request to validate the JWT token in the Authorization header.
-func JWTMiddleware(secret string) echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) error {
+// Tokens revoked by a global logout are rejected even if their signature is still valid.
+func JWTMiddleware(u *usecase.AuthUsecase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
@@ -23,10 +24,17 @@ func JWTMiddleware(secret string) echo.MiddlewareFunc {
 				return c.JSON(http.StatusUnauthorized, echo.Map{"error": "invalid authorization format"})
 			}
 
-			// Validate token using the security package logic
-			claims, err := security.ValidateToken(parts[1], secret)
+			// Validate signature, expiry and revocation status. Tokens issued to OAuth
+			// clients are for their resource servers, not for these endpoints.
+			claims, err := u.AuthenticateSession(c.Request().Context(), parts[1])
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, echo.Map{"error": "invalid or expired token"})
+				if err == usecase.ErrInvalidToken {
+					return c.JSON(http.StatusUnauthorized, echo.Map{"error": "invalid or expired token"})
+				}
+				if err == usecase.ErrClientToken {
+					return c.JSON(http.StatusForbidden, echo.Map{"error": "token issued to an oauth client is not accepted here"})
+				}
+				return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
 			}
 
 			// Inject extracted user information into Echo context.
@@ -41,16 +49,16 @@ func JWTMiddleware(secret string) echo.MiddlewareFunc {
 
 // RoleMiddleware ensures only users with specific roles (or admins) can access the route.
 func RoleMiddleware(requiredRole string) echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) error {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			role, ok := c.Get("role").(string)
-			
+
 			// Authorization logic: Admins have full access, others need the specific role.
 			if !ok || (role != requiredRole && role != "admin") {
 				return c.JSON(http.StatusForbidden, echo.Map{"error": "access denied: insufficient permissions"})
 			}
-			
+
 			return next(c)
 		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,277 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/FilipeAphrody/sentinel-auth/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// OAuthHandler exposes the OAuth 2.0 endpoints called by registered clients and the
+// admin endpoint that registers them.
+type OAuthHandler struct {
+	usecase  *usecase.OAuthUsecase
+	loginURL string
+}
+
+// NewOAuthHandler registers the OAuth routes. Client endpoints go on the public group and
+// authenticate the client themselves; approving an authorization request goes on the
+// protected group and registration on the admin group. loginURL is the login page the
+// authorization endpoint sends users to.
+func NewOAuthHandler(public, protected, admin *echo.Group, u *usecase.OAuthUsecase, loginURL string) {
+	handler := &OAuthHandler{usecase: u, loginURL: loginURL}
+
+	public.GET("/oauth/authorize", handler.Authorize)
+	public.POST("/oauth/token", handler.Token)
+	public.POST("/oauth/introspect", handler.Introspect)
+	public.POST("/oauth/revoke", handler.Revoke)
+
+	protected.POST("/oauth/authorize", handler.CompleteAuthorization)
+
+	admin.POST("/oauth/clients", handler.RegisterClient)
+}
+
+// registerClientRequest describes a client to register.
+type registerClientRequest struct {
+	Name         string   `json:"name" validate:"required"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Public       bool     `json:"public"`
+}
+
+// RegisterClient creates an OAuth client. The secret of a confidential client is only
+// returned in this response.
+func (h *OAuthHandler) RegisterClient(c echo.Context) error {
+	adminID, _ := c.Get("user_id").(string)
+
+	var req registerClientRequest
+	if err := c.Bind(&req); err != nil || req.Name == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	client, secret, err := h.usecase.RegisterClient(ctx, adminID, req.Name, req.RedirectURIs, req.Public)
+	if err != nil {
+		if err == usecase.ErrInvalidRedirectURI {
+			return c.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	resp := echo.Map{
+		"client_id":     client.ID,
+		"name":          client.Name,
+		"redirect_uris": client.RedirectURIs,
+		"created_at":    client.CreatedAt,
+	}
+	if secret != "" {
+		resp["client_secret"] = secret
+	}
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// Authorize is the authorization endpoint (RFC 6749 section 4.1.1). A valid request is
+// kept server-side and the user is redirected to the login page with its request_id.
+// Requests with an unknown client or redirect URI are answered here and never redirected,
+// so the endpoint cannot be used as an open redirector.
+func (h *OAuthHandler) Authorize(c echo.Context) error {
+	params := usecase.AuthorizeParams{
+		ResponseType:        c.QueryParam("response_type"),
+		ClientID:            c.QueryParam("client_id"),
+		RedirectURI:         c.QueryParam("redirect_uri"),
+		State:               c.QueryParam("state"),
+		CodeChallenge:       c.QueryParam("code_challenge"),
+		CodeChallengeMethod: c.QueryParam("code_challenge_method"),
+	}
+
+	requestID, err := h.usecase.StartAuthorization(c.Request().Context(), params)
+	if err != nil {
+		var authErr *usecase.AuthorizationError
+		switch {
+		case errors.As(err, &authErr):
+			return c.Redirect(http.StatusFound, authErr.RedirectURL())
+		case err == usecase.ErrInvalidClient:
+			return oauthError(c, http.StatusBadRequest, "invalid_client")
+		case err == usecase.ErrInvalidRedirectURI:
+			return oauthError(c, http.StatusBadRequest, "invalid_request")
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error")
+	}
+
+	target, err := url.Parse(h.loginURL)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error")
+	}
+	query := target.Query()
+	query.Set("request_id", requestID)
+	target.RawQuery = query.Encode()
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.Redirect(http.StatusFound, target.String())
+}
+
+// completeAuthorizationRequest answers a pending authorization request.
+type completeAuthorizationRequest struct {
+	RequestID string `json:"request_id" validate:"required"`
+	Deny      bool   `json:"deny"`
+}
+
+// CompleteAuthorization is called by the login page once the user has signed in through
+// /login (and /mfa/verify or a passkey when required), with the resulting access token.
+// It returns the client URL carrying the authorization code, for the page to navigate to.
+func (h *OAuthHandler) CompleteAuthorization(c echo.Context) error {
+	userID, _ := c.Get("user_id").(string)
+
+	var req completeAuthorizationRequest
+	if err := c.Bind(&req); err != nil || req.RequestID == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	redirectTo, err := h.usecase.CompleteAuthorization(c.Request().Context(), req.RequestID, userID, c.RealIP(), !req.Deny)
+	if err != nil {
+		if err == usecase.ErrInvalidAuthorizationRequest {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, echo.Map{"redirect_to": redirectTo})
+}
+
+// Token is the token endpoint (RFC 6749 section 3.2). It redeems authorization codes,
+// with the PKCE code_verifier, and rotates refresh tokens. Confidential clients
+// authenticate as for introspection; public clients send their client_id.
+func (h *OAuthHandler) Token(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	clientID, secret := clientCredentials(c)
+	client, err := h.usecase.IdentifyClient(ctx, clientID, secret)
+	if err != nil {
+		if err == usecase.ErrInvalidClient {
+			return invalidClientResponse(c)
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error")
+	}
+
+	var resp *domain.AuthResponse
+	switch c.FormValue("grant_type") {
+	case "authorization_code":
+		code, redirectURI, verifier := c.FormValue("code"), c.FormValue("redirect_uri"), c.FormValue("code_verifier")
+		if code == "" || redirectURI == "" || verifier == "" {
+			return oauthError(c, http.StatusBadRequest, "invalid_request")
+		}
+		resp, err = h.usecase.ExchangeCode(ctx, client, code, redirectURI, verifier, c.RealIP())
+	case "refresh_token":
+		refreshToken := c.FormValue("refresh_token")
+		if refreshToken == "" {
+			return oauthError(c, http.StatusBadRequest, "invalid_request")
+		}
+		resp, err = h.usecase.RefreshGrant(ctx, client, refreshToken)
+	case "":
+		return oauthError(c, http.StatusBadRequest, "invalid_request")
+	default:
+		return oauthError(c, http.StatusBadRequest, "unsupported_grant_type")
+	}
+	if err != nil {
+		if err == usecase.ErrInvalidGrant {
+			return oauthError(c, http.StatusBadRequest, "invalid_grant")
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error")
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+	return c.JSON(http.StatusOK, echo.Map{
+		"access_token":  resp.AccessToken,
+		"token_type":    "Bearer",
+		"expires_in":    resp.ExpiresIn,
+		"refresh_token": resp.RefreshToken,
+		"scope":         resp.Scope,
+	})
+}
+
+// Introspect implements RFC 7662. The caller authenticates as a confidential client with
+// HTTP Basic (client_secret_basic) or form fields (client_secret_post) and posts the
+// token as application/x-www-form-urlencoded.
+func (h *OAuthHandler) Introspect(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	clientID, secret := clientCredentials(c)
+	client, err := h.usecase.AuthenticateClient(ctx, clientID, secret)
+	if err != nil {
+		if err == usecase.ErrInvalidClient {
+			return invalidClientResponse(c)
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error")
+	}
+
+	token := c.FormValue("token")
+	if token == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request")
+	}
+
+	result, err := h.usecase.Introspect(ctx, client, token, c.FormValue("token_type_hint"))
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error")
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, result)
+}
+
+// Revoke implements RFC 7009. Confidential clients authenticate as for introspection;
+// public clients send their client_id. The response is 200 whether or not the token
+// was valid, so it cannot be used to probe tokens.
+func (h *OAuthHandler) Revoke(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	clientID, secret := clientCredentials(c)
+	client, err := h.usecase.IdentifyClient(ctx, clientID, secret)
+	if err != nil {
+		if err == usecase.ErrInvalidClient {
+			return invalidClientResponse(c)
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error")
+	}
+
+	token := c.FormValue("token")
+	if token == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request")
+	}
+
+	if err := h.usecase.Revoke(ctx, client, token, c.FormValue("token_type_hint")); err != nil {
+		return oauthError(c, http.StatusServiceUnavailable, "temporarily_unavailable")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// clientCredentials reads the client ID and secret from the Authorization header, where
+// both are form-encoded (RFC 6749 section 2.3.1), or else from the request body.
+func clientCredentials(c echo.Context) (string, string) {
+	if id, secret, ok := c.Request().BasicAuth(); ok {
+		if decoded, err := url.QueryUnescape(id); err == nil {
+			id = decoded
+		}
+		if decoded, err := url.QueryUnescape(secret); err == nil {
+			secret = decoded
+		}
+		return id, secret
+	}
+	return c.FormValue("client_id"), c.FormValue("client_secret")
+}
+
+// invalidClientResponse answers a failed client authentication (RFC 6749 section 5.2).
+func invalidClientResponse(c echo.Context) error {
+	c.Response().Header().Set("WWW-Authenticate", `Basic realm="sentinel-auth"`)
+	return oauthError(c, http.StatusUnauthorized, "invalid_client")
+}
+
+// oauthError writes an error in the OAuth 2.0 format, which uses codes instead of messages.
+func oauthError(c echo.Context, status int, code string) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(status, echo.Map{"error": code})
+}
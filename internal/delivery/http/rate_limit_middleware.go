@@ -0,0 +1,115 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimitKeyFunc identifies who a request is counted against.
+type RateLimitKeyFunc func(c echo.Context) string
+
+// RateLimitByIP counts requests per client IP. The IP comes from the server's
+// IPExtractor, so forwarding headers only count when sent by a trusted proxy and a
+// client cannot pick a fresh budget per request.
+func RateLimitByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// RateLimitByUser counts requests per authenticated user. It must run after JWTMiddleware;
+// anonymous requests fall back to the client IP.
+func RateLimitByUser(c echo.Context) string {
+	if userID, ok := c.Get("user_id").(string); ok && userID != "" {
+		return "user:" + userID
+	}
+	return RateLimitByIP(c)
+}
+
+// RateLimitByRoute shares one budget between all clients of a route.
+func RateLimitByRoute(c echo.Context) string {
+	return "route:" + c.Request().Method + " " + c.Path()
+}
+
+// RateLimitConfig is the budget applied by RateLimitMiddleware.
+type RateLimitConfig struct {
+	// Name namespaces the counters so groups with different budgets do not share them.
+	Name   string
+	Limit  int
+	Window time.Duration
+	// Key selects the counter for a request. Defaults to RateLimitByIP.
+	Key RateLimitKeyFunc
+	// Routes restricts the limit to these route paths (e.g. "/v1/login"). Empty means every route of the group.
+	Routes []string
+}
+
+// RateLimitMiddleware enforces a sliding-window request budget stored in Redis, so the limit
+// holds across replicas. Every response carries RateLimit-Limit/Remaining/Reset headers;
+// rejected requests get 429 with Retry-After. If the store is unavailable requests are let
+// through: availability of the API wins over the limiter.
+func RateLimitMiddleware(store domain.RateLimitRepository, cfg RateLimitConfig) echo.MiddlewareFunc {
+	if cfg.Key == nil {
+		cfg.Key = RateLimitByIP
+	}
+
+	routes := make(map[string]bool, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes[r] = true
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", cfg.Limit, int(cfg.Window.Seconds()))
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(routes) > 0 && !routes[c.Path()] {
+				return next(c)
+			}
+
+			key := cfg.Name + ":" + cfg.Key(c)
+			res, err := store.Allow(c.Request().Context(), key, cfg.Limit, cfg.Window)
+			if err != nil {
+				c.Logger().Errorf("rate limiter unavailable: %v", err)
+				return next(c)
+			}
+
+			reset := strconv.Itoa(int(math.Ceil(res.Reset.Seconds())))
+			header := c.Response().Header()
+			header.Set("RateLimit-Policy", policy)
+			header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			header.Set("RateLimit-Reset", reset)
+
+			if !res.Allowed {
+				header.Set("Retry-After", reset)
+				return c.JSON(http.StatusTooManyRequests, echo.Map{"error": "rate limit exceeded"})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// ParseRateLimit reads a budget written as "<requests>/<window>", e.g. "10/1m".
+func ParseRateLimit(s string) (int, time.Duration, error) {
+	count, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate limit %q: expected <requests>/<window>", s)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || limit <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q: request count must be a positive integer", s)
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q: window must be a positive duration", s)
+	}
+
+	return limit, d, nil
+}
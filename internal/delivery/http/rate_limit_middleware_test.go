@@ -0,0 +1,91 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/repository"
+)
+
+// newRateLimitedServer serves /limited under a 2 requests per minute budget and /free without one.
+func newRateLimitedServer(t *testing.T) (*echo.Echo, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	// No retries: the fail-open test should not wait out the client's backoff
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	e := echo.New()
+	e.Use(RateLimitMiddleware(repository.NewRedisRateLimitRepo(client), RateLimitConfig{
+		Name:   "test",
+		Limit:  2,
+		Window: time.Minute,
+		Routes: []string{"/limited"},
+	}))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	e.GET("/limited", ok)
+	e.GET("/free", ok)
+	return e, mr
+}
+
+func serve(e *echo.Echo, path, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitMiddlewareHeaders(t *testing.T) {
+	e, _ := newRateLimitedServer(t)
+
+	for i, remaining := range []string{"1", "0"} {
+		rec := serve(e, "/limited", "198.51.100.7")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+		h := rec.Header()
+		if h.Get("RateLimit-Policy") != "2;w=60" || h.Get("RateLimit-Limit") != "2" ||
+			h.Get("RateLimit-Remaining") != remaining || h.Get("RateLimit-Reset") != "60" {
+			t.Fatalf("request %d: headers %v", i+1, h)
+		}
+	}
+
+	rec := serve(e, "/limited", "198.51.100.7")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("over the limit: status %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("over the limit: headers %v", rec.Header())
+	}
+
+	// The budget is per client IP, and routes outside the list are not counted
+	if rec := serve(e, "/limited", "203.0.113.1"); rec.Code != http.StatusNoContent {
+		t.Fatalf("other IP: status %d", rec.Code)
+	}
+	if rec := serve(e, "/free", "198.51.100.7"); rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("unlisted route: status %d, headers %v", rec.Code, rec.Header())
+	}
+}
+
+func TestRateLimitMiddlewareFailsOpen(t *testing.T) {
+	e, mr := newRateLimitedServer(t)
+	mr.Close()
+
+	// With the store down requests are let through without limit headers
+	for i := 0; i < 3; i++ {
+		rec := serve(e, "/limited", "198.51.100.7")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status %d, want 204", i+1, rec.Code)
+		}
+		if rec.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("request %d: headers %v", i+1, rec.Header())
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// SessionHandler manages the sessions of the authenticated user.
+type SessionHandler struct {
+	usecase *usecase.AuthUsecase
+}
+
+// NewSessionHandler registers the session management routes.
+// The group must be protected by JWTMiddleware.
+func NewSessionHandler(e *echo.Group, u *usecase.AuthUsecase) {
+	handler := &SessionHandler{usecase: u}
+
+	e.POST("/logout/all", handler.LogoutAll)
+}
+
+// LogoutAll revokes every refresh token and outstanding access token of the caller.
+func (h *SessionHandler) LogoutAll(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.usecase.LogoutAll(ctx, userID); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
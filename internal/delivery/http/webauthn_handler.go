@@ -0,0 +1,202 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// WebAuthnHandler exposes the passkey ceremonies.
+type WebAuthnHandler struct {
+	usecase *usecase.WebAuthnUsecase
+}
+
+// NewWebAuthnHandler registers the WebAuthn routes. Login and MFA ceremonies are public;
+// credential management goes on the group protected by JWTMiddleware.
+func NewWebAuthnHandler(public, protected *echo.Group, u *usecase.WebAuthnUsecase) {
+	handler := &WebAuthnHandler{usecase: u}
+
+	// Passwordless login and passkey as a second factor
+	public.POST("/webauthn/login/begin", handler.BeginLogin)
+	public.POST("/webauthn/login/finish", handler.FinishLogin)
+	public.POST("/webauthn/mfa/begin", handler.BeginMFA)
+	public.POST("/webauthn/mfa/finish", handler.FinishMFA)
+
+	// Credential management (authenticated)
+	protected.POST("/webauthn/register/begin", handler.BeginRegistration)
+	protected.POST("/webauthn/register/finish", handler.FinishRegistration)
+	protected.GET("/webauthn/credentials", handler.ListCredentials)
+	protected.DELETE("/webauthn/credentials/:id", handler.DeleteCredential)
+}
+
+// webauthnRegisterRequest wraps the browser's attestation response.
+type webauthnRegisterRequest struct {
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+// webauthnMFARequest ties an assertion to the MFA challenge issued by /v1/login.
+type webauthnMFARequest struct {
+	MFAToken   string          `json:"mfa_token" validate:"required"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// webauthnLoginRequest ties an assertion to the session started by /webauthn/login/begin.
+type webauthnLoginRequest struct {
+	SessionToken string          `json:"session_token" validate:"required"`
+	Credential   json.RawMessage `json:"credential" validate:"required"`
+}
+
+// BeginRegistration returns PublicKeyCredentialCreationOptions for navigator.credentials.create().
+func (h *WebAuthnHandler) BeginRegistration(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	options, err := h.usecase.BeginRegistration(ctx, userID)
+	if err != nil {
+		return webauthnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, options)
+}
+
+// FinishRegistration verifies the attestation response and stores the passkey.
+func (h *WebAuthnHandler) FinishRegistration(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
+	var req webauthnRegisterRequest
+	if err := c.Bind(&req); err != nil || len(req.Credential) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	cred, err := h.usecase.FinishRegistration(ctx, userID, req.Name, req.Credential)
+	if err != nil {
+		if err == usecase.ErrInvalidPasskey {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return webauthnError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, cred)
+}
+
+// ListCredentials returns the caller's registered passkeys.
+func (h *WebAuthnHandler) ListCredentials(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	creds, err := h.usecase.ListCredentials(ctx, userID)
+	if err != nil {
+		return webauthnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"credentials": creds})
+}
+
+// DeleteCredential removes one of the caller's passkeys.
+func (h *WebAuthnHandler) DeleteCredential(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.usecase.DeleteCredential(ctx, userID, c.Param("id")); err != nil {
+		return webauthnError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// BeginMFA returns assertion options for the user behind an MFA challenge.
+func (h *WebAuthnHandler) BeginMFA(c echo.Context) error {
+	var req webauthnMFARequest
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	options, err := h.usecase.BeginMFA(ctx, req.MFAToken)
+	if err != nil {
+		return webauthnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, options)
+}
+
+// FinishMFA verifies the assertion and completes the login with tokens.
+func (h *WebAuthnHandler) FinishMFA(c echo.Context) error {
+	var req webauthnMFARequest
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" || len(req.Credential) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	resp, err := h.usecase.FinishMFA(ctx, req.MFAToken, req.Credential, c.RealIP())
+	if err != nil {
+		return webauthnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// BeginLogin starts a passwordless login with a discoverable credential.
+func (h *WebAuthnHandler) BeginLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+	options, token, err := h.usecase.BeginLogin(ctx)
+	if err != nil {
+		return webauthnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"session_token": token,
+		"options":       options,
+	})
+}
+
+// FinishLogin verifies a passwordless assertion and returns tokens.
+func (h *WebAuthnHandler) FinishLogin(c echo.Context) error {
+	var req webauthnLoginRequest
+	if err := c.Bind(&req); err != nil || req.SessionToken == "" || len(req.Credential) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	resp, err := h.usecase.FinishLogin(ctx, req.SessionToken, req.Credential, c.RealIP())
+	if err != nil {
+		return webauthnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// webauthnError maps usecase errors from the passkey ceremonies to HTTP responses.
+func webauthnError(c echo.Context, err error) error {
+	var throttle *usecase.ThrottleError
+	if errors.As(err, &throttle) {
+		return throttleResponse(c, throttle)
+	}
+
+	switch err {
+	case usecase.ErrUserNotFound, usecase.ErrCredentialNotFound:
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case usecase.ErrInvalidToken, usecase.ErrInvalidPasskey:
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+	case usecase.ErrEmailNotVerified:
+		return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+}
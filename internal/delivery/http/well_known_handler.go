@@ -0,0 +1,88 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+	"github.com/labstack/echo/v4"
+)
+
+// Cache lifetimes of the well-known documents. Keys are refetched often enough that a
+// newly published key is picked up well before it starts signing tokens.
+const (
+	jwksMaxAge      = "public, max-age=300, stale-while-revalidate=60"
+	discoveryMaxAge = "public, max-age=3600"
+)
+
+// WellKnownHandler serves the documents resource servers use to validate tokens on
+// their own: the JWKS and the OpenID Provider metadata.
+type WellKnownHandler struct {
+	keys    *security.Keyring
+	baseURL string
+}
+
+// NewWellKnownHandler registers /.well-known routes on the root group.
+// baseURL is the public URL of this API (PUBLIC_URL), used to build absolute endpoint URLs.
+func NewWellKnownHandler(e *echo.Group, keys *security.Keyring, baseURL string) {
+	handler := &WellKnownHandler{keys: keys, baseURL: strings.TrimRight(baseURL, "/")}
+
+	e.GET("/.well-known/jwks.json", handler.JWKS)
+	e.GET("/.well-known/openid-configuration", handler.OpenIDConfiguration)
+}
+
+// JWKS lists the public keys that sign, or recently signed, access tokens.
+func (h *WellKnownHandler) JWKS(c echo.Context) error {
+	return cachedJSON(c, jwksMaxAge, h.keys.JWKS())
+}
+
+// OpenIDConfiguration describes the issuer and its endpoints. No ID tokens are issued,
+// so no ID token signing algorithms are announced; the JWKS names the algorithm of each key.
+func (h *WellKnownHandler) OpenIDConfiguration(c echo.Context) error {
+	return cachedJSON(c, discoveryMaxAge, echo.Map{
+		"issuer":                                        security.TokenIssuer,
+		"jwks_uri":                                      h.baseURL + "/.well-known/jwks.json",
+		"authorization_endpoint":                        h.baseURL + "/v1/oauth/authorize",
+		"token_endpoint":                                h.baseURL + "/v1/oauth/token",
+		"response_types_supported":                      []string{"code"},
+		"grant_types_supported":                         []string{"authorization_code", "refresh_token"},
+		"code_challenge_methods_supported":              []string{"S256"},
+		"token_endpoint_auth_methods_supported":         []string{"client_secret_basic", "client_secret_post", "none"},
+		"subject_types_supported":                       []string{"public"},
+		"scopes_supported":                              []string{"profile"},
+		"claims_supported":                              []string{"iss", "iat", "nbf", "exp", "jti", "user_id", "role", "client_id", "scope"},
+		"introspection_endpoint":                        h.baseURL + "/v1/oauth/introspect",
+		"introspection_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"revocation_endpoint":                           h.baseURL + "/v1/oauth/revoke",
+		"revocation_endpoint_auth_methods_supported":    []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+// cachedJSON writes v with Cache-Control and a strong ETag, answering 304 Not Modified
+// when the client already holds the same representation.
+func cachedJSON(c echo.Context, cacheControl string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "internal server error"})
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", cacheControl)
+	header.Set("ETag", etag)
+
+	if match := c.Request().Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			if candidate = strings.TrimSpace(candidate); candidate == etag || candidate == "*" {
+				return c.NoContent(http.StatusNotModified)
+			}
+		}
+	}
+
+	return c.JSONBlob(http.StatusOK, body)
+}
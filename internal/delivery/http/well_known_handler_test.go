@@ -0,0 +1,57 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+func TestOpenIDConfiguration(t *testing.T) {
+	issuer := security.TokenIssuer
+	t.Cleanup(func() { security.TokenIssuer = issuer })
+	security.TokenIssuer = "https://api.example.com"
+
+	e := echo.New()
+	NewWellKnownHandler(e.Group(""), security.NewHMACKeyring("test-secret"), "https://api.example.com/")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]string{
+		"issuer":                 "https://api.example.com",
+		"jwks_uri":               "https://api.example.com/.well-known/jwks.json",
+		"authorization_endpoint": "https://api.example.com/v1/oauth/authorize",
+		"token_endpoint":         "https://api.example.com/v1/oauth/token",
+		"introspection_endpoint": "https://api.example.com/v1/oauth/introspect",
+		"revocation_endpoint":    "https://api.example.com/v1/oauth/revoke",
+	} {
+		if doc[field] != want {
+			t.Errorf("%s = %v, want %s", field, doc[field], want)
+		}
+	}
+	// No ID tokens are issued
+	if _, ok := doc["id_token_signing_alg_values_supported"]; ok {
+		t.Error("id_token_signing_alg_values_supported announced")
+	}
+
+	// The document is cacheable and revalidated by ETag
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("revalidation: status %d, want 304", rec.Code)
+	}
+}
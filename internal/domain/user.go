@@ -2,26 +2,83 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var (
+	// ErrUserAlreadyExists is returned by UserRepository.Create when the email is already taken.
+	ErrUserAlreadyExists = errors.New("user already exists")
+	// ErrRefreshTokenNotFound is returned when a refresh token is unknown or expired.
+	ErrRefreshTokenNotFound = errors.New("refresh token expired or invalid")
+	// ErrMFAChallengeNotFound is returned when an MFA challenge is unknown, used or expired.
+	ErrMFAChallengeNotFound = errors.New("mfa challenge expired or invalid")
+	// ErrWebAuthnSessionNotFound is returned when a WebAuthn ceremony is unknown or expired.
+	ErrWebAuthnSessionNotFound = errors.New("webauthn session expired or invalid")
+	// ErrWebAuthnCredentialNotFound is returned when a passkey does not exist or belongs to someone else.
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrOAuthClientNotFound is returned when no OAuth client is registered under an ID.
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	// ErrAuthorizationRequestNotFound is returned when an authorization request is unknown, used or expired.
+	ErrAuthorizationRequestNotFound = errors.New("authorization request expired or invalid")
+	// ErrAuthorizationCodeNotFound is returned when an authorization code is unknown, redeemed or expired.
+	ErrAuthorizationCodeNotFound = errors.New("authorization code expired or invalid")
+)
+
 // User represents the central identity entity of the system.
 type User struct {
-	ID           string    `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`          // Never expose the password hash in JSON
-	Role         string    `json:"role"`       // RBAC Role (admin, user, etc.)
-	MFAEnabled   bool      `json:"mfa_enabled"`
-	MFASecret    string    `json:"-"`          // TOTP secret key
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID            string    `json:"id"`
+	Email         string    `json:"email"`
+	PasswordHash  string    `json:"-"`    // Never expose the password hash in JSON
+	Role          string    `json:"role"` // RBAC Role (admin, user, etc.)
+	MFAEnabled    bool      `json:"mfa_enabled"`
+	MFASecret     string    `json:"-"` // TOTP secret key
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 // AuthResponse defines the payload returned after a successful login.
+// When MFA is pending only MFAToken is set and ExpiresIn refers to the challenge.
 type AuthResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int64  `json:"expires_in"`
+	AccessToken  string   `json:"access_token,omitempty"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	MFAToken     string   `json:"mfa_token,omitempty"`
+	MFAMethods   []string `json:"mfa_methods,omitempty"`
+	ExpiresIn    int64    `json:"expires_in"`
+	Scope        string   `json:"scope,omitempty"`
+}
+
+// RecoveryCode is a hashed, one-time code that can replace a TOTP code.
+type RecoveryCode struct {
+	ID       string
+	UserID   string
+	CodeHash string
+}
+
+// WebAuthnCredential is a registered passkey / security key.
+// Key material is never exposed in JSON.
+type WebAuthnCredential struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"-"`
+	CredentialID    []byte     `json:"-"`
+	PublicKey       []byte     `json:"-"`
+	AttestationType string     `json:"attestation_type"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"-"`
+	Transports      []string   `json:"transports"`
+	Name            string     `json:"name"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+}
+
+// MFAChallenge is the state kept between a successful password check and the
+// second factor. It is bound to a short-lived, single-use opaque token.
+type MFAChallenge struct {
+	UserID   string
+	Attempts int
 }
 
 // UserRepository defines the contract for user data persistence.
@@ -30,15 +87,255 @@ type UserRepository interface {
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	GetByID(ctx context.Context, id string) (*User, error)
 	Create(ctx context.Context, user *User) error
+	// Update saves the MFA and verification fields. The password hash is left alone:
+	// it only changes through UpdatePasswordHash.
 	Update(ctx context.Context, user *User) error
-	
+	// UpdatePasswordHash replaces the password hash only if it still equals oldHash and
+	// reports whether it did, so a stale read cannot overwrite a concurrent change.
+	UpdatePasswordHash(ctx context.Context, userID, oldHash, newHash string) (bool, error)
+
+	// ConsumeMFAStep records the start of the TOTP time step (Unix seconds) of an
+	// accepted code. It only succeeds if that time is later than the last accepted
+	// one, so each code works once.
+	ConsumeMFAStep(ctx context.Context, userID string, stepTime int64) (bool, error)
+
+	// CountPasswordHashPrefixes groups accounts by the scheme prefix of their password hash
+	// (e.g. "$argon2id$", "$2b$", "pbkdf2_sha256$"), to track legacy hashes left to upgrade.
+	CountPasswordHashPrefixes(ctx context.Context) (map[string]int, error)
+
+	// Password history (previous Argon2id hashes, newest first)
+	GetPasswordHistory(ctx context.Context, userID string, limit int) ([]string, error)
+	// AddPasswordHistory records a retired hash and prunes all but the newest keep entries.
+	AddPasswordHistory(ctx context.Context, userID, passwordHash string, keep int) error
+
+	// MFA recovery codes (stored as Argon2id hashes)
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	GetUnusedRecoveryCodes(ctx context.Context, userID string) ([]RecoveryCode, error)
+	// MarkRecoveryCodeUsed flags a code as spent and reports whether this call spent it.
+	MarkRecoveryCodeUsed(ctx context.Context, id string) (bool, error)
+
+	// WebAuthn (passkey) credentials
+	CreateWebAuthnCredential(ctx context.Context, cred *WebAuthnCredential) error
+	GetWebAuthnCredentials(ctx context.Context, userID string) ([]WebAuthnCredential, error)
+	UpdateWebAuthnSignCount(ctx context.Context, id string, signCount uint32) error
+	DeleteWebAuthnCredential(ctx context.Context, userID, id string) error
+
 	// LogSecurityEvent is used for the Audit Logs requirement
 	LogSecurityEvent(ctx context.Context, userID, eventType, ip string, metadata map[string]interface{}) error
 }
 
+// RefreshSession identifies the owner of a refresh token and the rotation family it belongs to.
+// Every token minted by rotating another one inherits its FamilyID.
+type RefreshSession struct {
+	UserID   string
+	FamilyID string
+	// ClientID is the OAuth client the token was issued to; empty for first-party sessions.
+	ClientID string
+	// ExpiresAt is only filled in by TokenRepository.GetRefreshSession.
+	ExpiresAt time.Time
+}
+
 // TokenRepository defines how we handle opaque refresh tokens (usually in Redis).
 type TokenRepository interface {
-	StoreRefreshToken(ctx context.Context, userID string, token string, ttl time.Duration) error
+	StoreRefreshToken(ctx context.Context, session RefreshSession, token string, ttl time.Duration) error
 	GetUserIDByRefreshToken(ctx context.Context, token string) (string, error)
+	// GetRefreshSession looks a live refresh token up without consuming it.
+	GetRefreshSession(ctx context.Context, token string) (*RefreshSession, error)
 	DeleteRefreshToken(ctx context.Context, token string) error
-}
\ No newline at end of file
+
+	// ConsumeRefreshToken atomically invalidates a token and returns its session.
+	// Presenting a token that was already consumed returns ErrRefreshTokenReused
+	// together with the session, so the caller can revoke the whole family.
+	ConsumeRefreshToken(ctx context.Context, token string) (*RefreshSession, error)
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+
+	// Per-user index used for "sign out everywhere".
+	ListTokenFamilies(ctx context.Context, userID string) ([]string, error)
+	RevokeAllUserTokens(ctx context.Context, userID string) error
+
+	// SetTokensValidAfter rejects every access token issued at or before t. The marker
+	// only needs to live as long as the longest access token TTL.
+	SetTokensValidAfter(ctx context.Context, userID string, t time.Time, ttl time.Duration) error
+	GetTokensValidAfter(ctx context.Context, userID string) (time.Time, error)
+
+	// DenyAccessToken rejects the access token with the given jti until ttl elapses,
+	// which should be the remaining lifetime of the token.
+	DenyAccessToken(ctx context.Context, jti string, ttl time.Duration) error
+	IsAccessTokenDenied(ctx context.Context, jti string) (bool, error)
+}
+
+// VerificationTokenRepository stores single-use, expiring tokens that prove ownership
+// of an email address. Like refresh tokens, these are usually kept in Redis.
+type VerificationTokenRepository interface {
+	StoreVerificationToken(ctx context.Context, userID string, token string, ttl time.Duration) error
+	// ConsumeVerificationToken returns the owner of the token and deletes it atomically,
+	// so a token can never be redeemed twice.
+	ConsumeVerificationToken(ctx context.Context, token string) (string, error)
+
+	// StorePasswordResetToken saves the hash of a reset token. Any earlier reset token
+	// of the same user is invalidated.
+	StorePasswordResetToken(ctx context.Context, userID string, tokenHash string, ttl time.Duration) error
+	// GetPasswordResetToken returns the owner of the token hash without consuming it.
+	GetPasswordResetToken(ctx context.Context, tokenHash string) (string, error)
+	// ConsumePasswordResetToken returns the owner of the token hash and deletes it atomically.
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error)
+}
+
+// MFAChallengeRepository stores pending MFA challenges (usually in Redis).
+type MFAChallengeRepository interface {
+	StoreMFAChallenge(ctx context.Context, token string, userID string, ttl time.Duration) error
+	GetMFAChallenge(ctx context.Context, token string) (*MFAChallenge, error)
+	// ReserveMFAChallengeAttempt atomically counts an attempt before the code is checked
+	// and returns the new attempt count.
+	ReserveMFAChallengeAttempt(ctx context.Context, token string) (int, error)
+	// DeleteMFAChallenge removes the challenge and reports whether it still existed,
+	// which makes a successful verification single-use even under concurrency.
+	DeleteMFAChallenge(ctx context.Context, token string) (bool, error)
+}
+
+// WebAuthnSessionRepository keeps the server-side state of an in-flight WebAuthn
+// ceremony (challenge, allowed credentials) between the begin and finish calls.
+type WebAuthnSessionRepository interface {
+	StoreWebAuthnSession(ctx context.Context, key string, data []byte, ttl time.Duration) error
+	// ConsumeWebAuthnSession returns the session and deletes it, so a challenge is used once.
+	ConsumeWebAuthnSession(ctx context.Context, key string) ([]byte, error)
+}
+
+// LoginAttemptRepository tracks failed authentication attempts and temporary blocks
+// (usually in Redis). Keys are namespaced by the caller, e.g. "account:<email>" or "ip:<addr>".
+type LoginAttemptRepository interface {
+	// RecordFailure increments the failure counter for key and returns the new count.
+	// The counter expires window after the first failure.
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	ResetFailures(ctx context.Context, key string) error
+
+	Lock(ctx context.Context, key string, ttl time.Duration) error
+	// LockedFor returns the remaining lock time, or zero if key is not locked.
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	Unlock(ctx context.Context, key string) error
+}
+
+// RateLimitResult describes a rate limit bucket after a request was counted against it.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the oldest request in the window expires and frees a slot.
+	Reset time.Duration
+}
+
+// RateLimitRepository counts requests in a sliding window shared by all replicas (usually Redis).
+type RateLimitRepository interface {
+	// Allow records a request for key if fewer than limit requests were made in the last window.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error)
+}
+
+// Signing key lifecycle states. A key is published while pending so resource servers
+// cache it before it signs anything, and stays published after retirement until every
+// token it signed has expired.
+const (
+	SigningKeyPending = "pending"
+	SigningKeyActive  = "active"
+	SigningKeyRetired = "retired"
+	SigningKeyDeleted = "deleted"
+)
+
+// SigningKey is a JWT signing key as persisted. The private key (PKCS#8) is encrypted;
+// only the key manager can read it.
+type SigningKey struct {
+	ID           string // kid
+	Algorithm    string
+	State        string
+	EncryptedKey []byte
+	CreatedAt    time.Time
+	ActivatedAt  *time.Time
+	RetiredAt    *time.Time
+}
+
+// SigningKeyRepository persists signing keys shared by every replica.
+type SigningKeyRepository interface {
+	// ListSigningKeys returns every key that has not been deleted, oldest first.
+	ListSigningKeys(ctx context.Context) ([]*SigningKey, error)
+	CreateSigningKey(ctx context.Context, key *SigningKey) error
+	// ActivateSigningKey promotes a pending key and retires the active one atomically.
+	ActivateSigningKey(ctx context.Context, id string) error
+	// DeleteSigningKey wipes the key material and marks the key deleted.
+	DeleteSigningKey(ctx context.Context, id string) error
+	// TryLockSigningKeys takes the cluster-wide rotation lock without waiting.
+	// ok is false if another replica holds it; otherwise unlock must be called.
+	TryLockSigningKeys(ctx context.Context) (unlock func(), ok bool, err error)
+}
+
+// OAuthClient is an application registered to call the OAuth endpoints. Confidential
+// clients authenticate with a secret, stored as its SHA-256; public clients have none.
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	Name         string    `json:"name"`
+	SecretHash   string    `json:"-"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Confidential reports whether the client authenticates with a secret.
+func (c *OAuthClient) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// OAuthClientRepository persists registered OAuth clients.
+type OAuthClientRepository interface {
+	CreateOAuthClient(ctx context.Context, client *OAuthClient) error
+	GetOAuthClient(ctx context.Context, id string) (*OAuthClient, error)
+}
+
+// AuthorizationRequest is a validated request of the authorization endpoint, kept while
+// the user signs in. CodeChallenge is the S256 PKCE challenge (RFC 7636).
+type AuthorizationRequest struct {
+	ClientID      string `json:"client_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	State         string `json:"state,omitempty"`
+	CodeChallenge string `json:"code_challenge"`
+}
+
+// AuthorizationCode is what an authorization code grants once redeemed: tokens for
+// UserID, to the client and redirect URI of the original request.
+type AuthorizationCode struct {
+	ClientID      string `json:"client_id"`
+	UserID        string `json:"user_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	CodeChallenge string `json:"code_challenge"`
+}
+
+// AuthorizationCodeRepository keeps the short-lived state of the authorization code grant
+// (usually in Redis). Both kinds of entries are looked up by the hash of their handle.
+type AuthorizationCodeRepository interface {
+	StoreAuthorizationRequest(ctx context.Context, idHash string, req AuthorizationRequest, ttl time.Duration) error
+	// ConsumeAuthorizationRequest returns the request and deletes it, so it is approved once.
+	ConsumeAuthorizationRequest(ctx context.Context, idHash string) (*AuthorizationRequest, error)
+	StoreAuthorizationCode(ctx context.Context, codeHash string, code AuthorizationCode, ttl time.Duration) error
+	// ConsumeAuthorizationCode returns the code and deletes it, so it is redeemed once.
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*AuthorizationCode, error)
+}
+
+// TokenIntrospection is the RFC 7662 description of a token. Only Active is set for
+// tokens that are unknown, expired or revoked.
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+	Role      string `json:"role,omitempty"`
+}
+
+// Mailer delivers transactional emails (verification links, notices) to users.
+type Mailer interface {
+	SendVerificationEmail(ctx context.Context, email, token string) error
+	SendPasswordResetEmail(ctx context.Context, email, token string) error
+	// SendAccountExistsEmail tells the owner that someone tried to register with their address.
+	SendAccountExistsEmail(ctx context.Context, email string) error
+}
@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+)
+
+// LogMailer implements domain.Mailer by writing messages to the application log.
+// It is meant for local development where no SMTP relay is available.
+type LogMailer struct {
+	baseURL string
+}
+
+// NewLogMailer creates a mailer that logs links built on top of baseURL.
+func NewLogMailer(baseURL string) *LogMailer {
+	return &LogMailer{baseURL: baseURL}
+}
+
+// SendVerificationEmail logs the verification link instead of sending it.
+func (m *LogMailer) SendVerificationEmail(ctx context.Context, email, token string) error {
+	log.Printf("[mailer] verification link for %s: %s/verify-email?token=%s", email, m.baseURL, token)
+	return nil
+}
+
+// SendPasswordResetEmail logs the password reset link instead of sending it.
+func (m *LogMailer) SendPasswordResetEmail(ctx context.Context, email, token string) error {
+	log.Printf("[mailer] password reset link for %s: %s/reset-password?token=%s", email, m.baseURL, token)
+	return nil
+}
+
+// SendAccountExistsEmail logs the notice instead of sending it.
+func (m *LogMailer) SendAccountExistsEmail(ctx context.Context, email string) error {
+	log.Printf("[mailer] account exists notice for %s", email)
+	return nil
+}
+
+// SMTPMailer implements domain.Mailer using a plain SMTP relay.
+type SMTPMailer struct {
+	addr    string
+	auth    smtp.Auth
+	from    string
+	baseURL string
+}
+
+// NewSMTPMailer creates a mailer that delivers through the relay at host:port.
+// Credentials are optional; when username is empty no AUTH is attempted.
+func NewSMTPMailer(host, port, username, password, from, baseURL string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		addr:    host + ":" + port,
+		auth:    auth,
+		from:    from,
+		baseURL: baseURL,
+	}
+}
+
+// SendVerificationEmail sends the account verification link to the user.
+func (m *SMTPMailer) SendVerificationEmail(ctx context.Context, email, token string) error {
+	body := fmt.Sprintf("Welcome to Sentinel!\r\n\r\nConfirm your email address by opening the link below:\r\n%s/verify-email?token=%s\r\n", m.baseURL, token)
+	return m.send(email, "Verify your email address", body)
+}
+
+// SendPasswordResetEmail sends the password reset link to the user.
+func (m *SMTPMailer) SendPasswordResetEmail(ctx context.Context, email, token string) error {
+	body := fmt.Sprintf("A password reset was requested for your Sentinel account.\r\n\r\nChoose a new password by opening the link below:\r\n%s/reset-password?token=%s\r\n\r\nIf you did not ask for this, you can ignore this message.\r\n", m.baseURL, token)
+	return m.send(email, "Reset your password", body)
+}
+
+// SendAccountExistsEmail warns the owner about a sign-up attempt with their address.
+func (m *SMTPMailer) SendAccountExistsEmail(ctx context.Context, email string) error {
+	body := fmt.Sprintf("Someone tried to create a Sentinel account with this email address, but you already have one.\r\n\r\nIf this was you, sign in at %s. Otherwise you can ignore this message.\r\n", m.baseURL)
+	return m.send(email, "You already have an account", body)
+}
+
+// send writes a minimal RFC 5322 message to the relay.
+func (m *SMTPMailer) send(to, subject, body string) error {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		m.from, to, subject, body)
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+)
+
+// PostgresOAuthClientRepo implements domain.OAuthClientRepository using PostgreSQL.
+type PostgresOAuthClientRepo struct {
+	db *sql.DB
+}
+
+// NewPostgresOAuthClientRepo creates a new repository instance.
+func NewPostgresOAuthClientRepo(db *sql.DB) *PostgresOAuthClientRepo {
+	return &PostgresOAuthClientRepo{db: db}
+}
+
+// CreateOAuthClient registers a new client.
+func (r *PostgresOAuthClientRepo) CreateOAuthClient(ctx context.Context, client *domain.OAuthClient) error {
+	if client.CreatedAt.IsZero() {
+		client.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, created_at) VALUES ($1, $2, NULLIF($3, ''), $4, $5)",
+		client.ID, client.Name, client.SecretHash, pq.Array(client.RedirectURIs), client.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store oauth client: %w", err)
+	}
+	return nil
+}
+
+// GetOAuthClient retrieves a client by its ID.
+func (r *PostgresOAuthClientRepo) GetOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	query := `
+		SELECT id, name, COALESCE(secret_hash, ''), redirect_uris, created_at
+		FROM oauth_clients
+		WHERE id = $1
+	`
+
+	client := &domain.OAuthClient{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&client.ID,
+		&client.Name,
+		&client.SecretHash,
+		pq.Array(&client.RedirectURIs),
+		&client.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrOAuthClientNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return client, nil
+}
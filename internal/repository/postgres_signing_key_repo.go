@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+)
+
+// signingKeyLockID is the Postgres advisory lock that serializes key rotation across replicas.
+const signingKeyLockID = 0x5e471e11
+
+// PostgresSigningKeyRepo implements domain.SigningKeyRepository using PostgreSQL.
+type PostgresSigningKeyRepo struct {
+	db *sql.DB
+}
+
+// NewPostgresSigningKeyRepo creates a new repository instance.
+func NewPostgresSigningKeyRepo(db *sql.DB) *PostgresSigningKeyRepo {
+	return &PostgresSigningKeyRepo{db: db}
+}
+
+// ListSigningKeys returns every key that has not been deleted, oldest first.
+func (r *PostgresSigningKeyRepo) ListSigningKeys(ctx context.Context) ([]*domain.SigningKey, error) {
+	query := `
+		SELECT id, algorithm, state, encrypted_key, created_at, activated_at, retired_at
+		FROM signing_keys
+		WHERE state <> 'deleted'
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*domain.SigningKey
+	for rows.Next() {
+		key := &domain.SigningKey{}
+		var activatedAt, retiredAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Algorithm, &key.State, &key.EncryptedKey, &key.CreatedAt, &activatedAt, &retiredAt); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		if activatedAt.Valid {
+			key.ActivatedAt = &activatedAt.Time
+		}
+		if retiredAt.Valid {
+			key.RetiredAt = &retiredAt.Time
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// CreateSigningKey stores a new key. Keys are normally created pending; a first key may be
+// created active directly.
+func (r *PostgresSigningKeyRepo) CreateSigningKey(ctx context.Context, key *domain.SigningKey) error {
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO signing_keys (id, algorithm, state, encrypted_key, created_at, activated_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		key.ID, key.Algorithm, key.State, key.EncryptedKey, key.CreatedAt, key.ActivatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store signing key: %w", err)
+	}
+	return nil
+}
+
+// ActivateSigningKey retires the active key and promotes the pending one in a single
+// transaction, so readers never see zero or two active keys.
+func (r *PostgresSigningKeyRepo) ActivateSigningKey(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE signing_keys SET state = 'retired', retired_at = $1 WHERE state = 'active'", now); err != nil {
+		return fmt.Errorf("failed to retire signing key: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE signing_keys SET state = 'active', activated_at = $1 WHERE id = $2 AND state = 'pending'", now, id)
+	if err != nil {
+		return fmt.Errorf("failed to activate signing key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("signing key is not pending")
+	}
+
+	return tx.Commit()
+}
+
+// DeleteSigningKey wipes the key material of a retired key and marks it deleted.
+// The row is kept as a record of the kid.
+func (r *PostgresSigningKeyRepo) DeleteSigningKey(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE signing_keys SET state = 'deleted', encrypted_key = NULL, deleted_at = $1 WHERE id = $2 AND state = 'retired'",
+		time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete signing key: %w", err)
+	}
+	return nil
+}
+
+// TryLockSigningKeys takes a session-level advisory lock on a dedicated connection,
+// which is held until unlock is called.
+func (r *PostgresSigningKeyRepo) TryLockSigningKeys(ctx context.Context) (func(), bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("database error: %w", err)
+	}
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", signingKeyLockID).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("database error: %w", err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", signingKeyLockID)
+		conn.Close()
+	}
+	return unlock, true, nil
+}
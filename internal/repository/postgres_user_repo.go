@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
+
 	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
 )
 
@@ -25,7 +27,7 @@ func NewPostgresUserRepo(db *sql.DB) *PostgresUserRepo {
 func (r *PostgresUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	// We join with 'roles' to get the role name directly, avoiding N+1 queries.
 	query := `
-		SELECT u.id, u.email, u.password_hash, r.name, u.mfa_enabled, COALESCE(u.mfa_secret, ''), u.created_at, u.updated_at
+		SELECT u.id, u.email, u.password_hash, r.name, u.mfa_enabled, COALESCE(u.mfa_secret, ''), u.email_verified, u.created_at, u.updated_at
 		FROM users u
 		JOIN roles r ON u.role_id = r.id
 		WHERE u.email = $1
@@ -39,6 +41,7 @@ func (r *PostgresUserRepo) GetByEmail(ctx context.Context, email string) (*domai
 		&user.Role,
 		&user.MFAEnabled,
 		&user.MFASecret,
+		&user.EmailVerified,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -56,7 +59,7 @@ func (r *PostgresUserRepo) GetByEmail(ctx context.Context, email string) (*domai
 // GetByID retrieves a user by their UUID.
 func (r *PostgresUserRepo) GetByID(ctx context.Context, id string) (*domain.User, error) {
 	query := `
-		SELECT u.id, u.email, u.password_hash, r.name, u.mfa_enabled, COALESCE(u.mfa_secret, ''), u.created_at, u.updated_at
+		SELECT u.id, u.email, u.password_hash, r.name, u.mfa_enabled, COALESCE(u.mfa_secret, ''), u.email_verified, u.created_at, u.updated_at
 		FROM users u
 		JOIN roles r ON u.role_id = r.id
 		WHERE u.id = $1
@@ -70,6 +73,7 @@ func (r *PostgresUserRepo) GetByID(ctx context.Context, id string) (*domain.User
 		&user.Role,
 		&user.MFAEnabled,
 		&user.MFASecret,
+		&user.EmailVerified,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -95,15 +99,15 @@ func (r *PostgresUserRepo) Create(ctx context.Context, user *domain.User) error
 
 	// 2. Insert User
 	query := `
-		INSERT INTO users (email, password_hash, role_id, mfa_enabled, mfa_secret, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO users (email, password_hash, role_id, mfa_enabled, mfa_secret, email_verified, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
 	`
 
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 
-	// Use COALESCE logic in Go: if secret is empty, send NULL to DB if configured, 
+	// Use COALESCE logic in Go: if secret is empty, send NULL to DB if configured,
 	// or empty string depending on DB constraint. Here we send string.
 	var mfaSecret sql.NullString
 	if user.MFASecret != "" {
@@ -117,34 +121,39 @@ func (r *PostgresUserRepo) Create(ctx context.Context, user *domain.User) error
 		roleID,
 		user.MFAEnabled,
 		mfaSecret,
+		user.EmailVerified,
 		user.CreatedAt,
 		user.UpdatedAt,
 	).Scan(&user.ID)
 
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return domain.ErrUserAlreadyExists
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
 	return nil
 }
 
-// Update modifies an existing user's MFA status and secret.
+// Update modifies an existing user's MFA status, secret and email verification flag.
 func (r *PostgresUserRepo) Update(ctx context.Context, user *domain.User) error {
 	query := `
 		UPDATE users 
-		SET mfa_enabled = $1, mfa_secret = $2, updated_at = $3
-		WHERE id = $4
+		SET mfa_enabled = $1, mfa_secret = $2, email_verified = $3, updated_at = $4
+		WHERE id = $5
 	`
-	
+
 	user.UpdatedAt = time.Now()
-	
+
 	var mfaSecret sql.NullString
 	if user.MFASecret != "" {
 		mfaSecret.String = user.MFASecret
 		mfaSecret.Valid = true
 	}
 
-	result, err := r.db.ExecContext(ctx, query, user.MFAEnabled, mfaSecret, user.UpdatedAt, user.ID)
+	result, err := r.db.ExecContext(ctx, query, user.MFAEnabled, mfaSecret, user.EmailVerified, user.UpdatedAt, user.ID)
 	if err != nil {
 		return err
 	}
@@ -157,7 +166,288 @@ func (r *PostgresUserRepo) Update(ctx context.Context, user *domain.User) error
 	return nil
 }
 
+// UpdatePasswordHash swaps the password hash if it is still oldHash. The comparison
+// happens in the UPDATE itself, so of two racing writers only the first one wins.
+func (r *PostgresUserRepo) UpdatePasswordHash(ctx context.Context, userID, oldHash, newHash string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET password_hash = $1, updated_at = $2 WHERE id = $3 AND password_hash = $4",
+		newHash, time.Now(), userID, oldHash)
+	if err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows == 1, nil
+}
+
+// ConsumeMFAStep advances the start time of the user's last accepted TOTP step. The
+// conditional update is atomic, so two requests racing with the same code cannot both win.
+func (r *PostgresUserRepo) ConsumeMFAStep(ctx context.Context, userID string, stepTime int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET mfa_last_step = $1 WHERE id = $2 AND mfa_last_step < $1",
+		stepTime, userID)
+	if err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows == 1, nil
+}
+
+// ReplaceRecoveryCodes deletes every existing recovery code of the user and stores the new set.
+// Both steps run in a single transaction so the user is never left without codes by a partial failure.
+func (r *PostgresUserRepo) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)",
+			userID, hash, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CountPasswordHashPrefixes counts users per hash scheme prefix: everything up to and
+// including the first '$' after the optional leading one.
+func (r *PostgresUserRepo) CountPasswordHashPrefixes(ctx context.Context) (map[string]int, error) {
+	query := `
+		SELECT COALESCE(substring(password_hash from '^\$?[^$]*\$'), ''), COUNT(*)
+		FROM users
+		GROUP BY 1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var prefix string
+		var count int
+		if err := rows.Scan(&prefix, &count); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		counts[prefix] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetPasswordHistory returns up to limit previous password hashes, newest first.
+func (r *PostgresUserRepo) GetPasswordHistory(ctx context.Context, userID string, limit int) ([]string, error) {
+	query := `
+		SELECT password_hash
+		FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// AddPasswordHistory stores a retired password hash and drops the entries beyond keep.
+func (r *PostgresUserRepo) AddPasswordHistory(ctx context.Context, userID, passwordHash string, keep int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO password_history (user_id, password_hash, created_at) VALUES ($1, $2, $3)",
+		userID, passwordHash, time.Now()); err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	prune := `
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = $1
+			ORDER BY created_at DESC
+			LIMIT $2
+		)
+	`
+	if _, err := tx.ExecContext(ctx, prune, userID, keep); err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetUnusedRecoveryCodes returns the recovery codes of a user that have not been spent yet.
+func (r *PostgresUserRepo) GetUnusedRecoveryCodes(ctx context.Context, userID string) ([]domain.RecoveryCode, error) {
+	query := `
+		SELECT id, user_id, code_hash
+		FROM mfa_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []domain.RecoveryCode
+	for rows.Next() {
+		var code domain.RecoveryCode
+		if err := rows.Scan(&code.ID, &code.UserID, &code.CodeHash); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+// MarkRecoveryCodeUsed spends a recovery code. The "used_at IS NULL" guard makes
+// the update a compare-and-set, so a code can only be redeemed once.
+func (r *PostgresUserRepo) MarkRecoveryCodeUsed(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE mfa_recovery_codes SET used_at = $1 WHERE id = $2 AND used_at IS NULL",
+		time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows == 1, nil
+}
+
+// CreateWebAuthnCredential stores a newly registered passkey.
+func (r *PostgresUserRepo) CreateWebAuthnCredential(ctx context.Context, cred *domain.WebAuthnCredential) error {
+	query := `
+		INSERT INTO webauthn_credentials (user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports, name, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+
+	cred.CreatedAt = time.Now()
+
+	err := r.db.QueryRowContext(ctx, query,
+		cred.UserID,
+		cred.CredentialID,
+		cred.PublicKey,
+		cred.AttestationType,
+		cred.AAGUID,
+		int64(cred.SignCount),
+		pq.Array(cred.Transports),
+		cred.Name,
+		cred.CreatedAt,
+	).Scan(&cred.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create webauthn credential: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebAuthnCredentials returns every passkey registered by the user.
+func (r *PostgresUserRepo) GetWebAuthnCredentials(ctx context.Context, userID string) ([]domain.WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, credential_id, public_key, attestation_type, COALESCE(aaguid, ''::bytea), sign_count,
+		       COALESCE(transports, '{}'), COALESCE(name, ''), created_at, last_used_at
+		FROM webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var creds []domain.WebAuthnCredential
+	for rows.Next() {
+		var cred domain.WebAuthnCredential
+		var signCount int64
+		var lastUsed sql.NullTime
+		err := rows.Scan(
+			&cred.ID,
+			&cred.UserID,
+			&cred.CredentialID,
+			&cred.PublicKey,
+			&cred.AttestationType,
+			&cred.AAGUID,
+			&signCount,
+			pq.Array(&cred.Transports),
+			&cred.Name,
+			&cred.CreatedAt,
+			&lastUsed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		cred.SignCount = uint32(signCount)
+		if lastUsed.Valid {
+			cred.LastUsedAt = &lastUsed.Time
+		}
+		creds = append(creds, cred)
+	}
+
+	return creds, rows.Err()
+}
+
+// UpdateWebAuthnSignCount stores the latest signature counter after a successful assertion.
+func (r *PostgresUserRepo) UpdateWebAuthnSignCount(ctx context.Context, id string, signCount uint32) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE webauthn_credentials SET sign_count = $1, last_used_at = $2 WHERE id = $3",
+		int64(signCount), time.Now(), id)
+	return err
+}
+
+// DeleteWebAuthnCredential removes a passkey owned by the user.
+func (r *PostgresUserRepo) DeleteWebAuthnCredential(ctx context.Context, userID, id string) error {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2",
+		id, userID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return domain.ErrWebAuthnCredentialNotFound
+	}
+
+	return nil
+}
+
 // LogSecurityEvent inserts an immutable record into the audit_logs table.
+// An empty ip is stored as NULL, since "" is not a valid INET value.
 func (r *PostgresUserRepo) LogSecurityEvent(ctx context.Context, userID, eventType, ip string, metadata map[string]interface{}) error {
 	metaJSON, err := json.Marshal(metadata)
 	if err != nil {
@@ -166,7 +456,7 @@ func (r *PostgresUserRepo) LogSecurityEvent(ctx context.Context, userID, eventTy
 
 	query := `
 		INSERT INTO audit_logs (user_id, event_type, ip_address, metadata, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		VALUES ($1, $2, NULLIF($3, '')::inet, $4, $5)
 	`
 
 	// Handle case where userID is empty (e.g. anonymous failed login)
@@ -179,4 +469,4 @@ func (r *PostgresUserRepo) LogSecurityEvent(ctx context.Context, userID, eventTy
 
 	_, err = r.db.ExecContext(ctx, query, uid, eventType, ip, metaJSON, time.Now())
 	return err
-}
\ No newline at end of file
+}
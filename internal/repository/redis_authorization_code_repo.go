@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+)
+
+// RedisAuthorizationCodeRepo implements domain.AuthorizationCodeRepository using Redis.
+type RedisAuthorizationCodeRepo struct {
+	client *redis.Client
+}
+
+// NewRedisAuthorizationCodeRepo creates a new repository instance.
+func NewRedisAuthorizationCodeRepo(client *redis.Client) *RedisAuthorizationCodeRepo {
+	return &RedisAuthorizationCodeRepo{client: client}
+}
+
+// StoreAuthorizationRequest saves a pending authorization request.
+// The key pattern is "auth:oauth:request:<hash>" -> JSON request.
+func (r *RedisAuthorizationCodeRepo) StoreAuthorizationRequest(ctx context.Context, idHash string, req domain.AuthorizationRequest, ttl time.Duration) error {
+	return r.store(ctx, fmt.Sprintf("auth:oauth:request:%s", idHash), req, ttl)
+}
+
+// ConsumeAuthorizationRequest atomically reads and deletes a pending request (GETDEL).
+func (r *RedisAuthorizationCodeRepo) ConsumeAuthorizationRequest(ctx context.Context, idHash string) (*domain.AuthorizationRequest, error) {
+	req := &domain.AuthorizationRequest{}
+	if err := r.consume(ctx, fmt.Sprintf("auth:oauth:request:%s", idHash), req); err != nil {
+		if err == redis.Nil {
+			return nil, domain.ErrAuthorizationRequestNotFound
+		}
+		return nil, err
+	}
+	return req, nil
+}
+
+// StoreAuthorizationCode saves an issued code.
+// The key pattern is "auth:oauth:code:<hash>" -> JSON grant.
+func (r *RedisAuthorizationCodeRepo) StoreAuthorizationCode(ctx context.Context, codeHash string, code domain.AuthorizationCode, ttl time.Duration) error {
+	return r.store(ctx, fmt.Sprintf("auth:oauth:code:%s", codeHash), code, ttl)
+}
+
+// ConsumeAuthorizationCode atomically reads and deletes a code (GETDEL), so a code
+// presented twice, even concurrently, is only redeemed once.
+func (r *RedisAuthorizationCodeRepo) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*domain.AuthorizationCode, error) {
+	code := &domain.AuthorizationCode{}
+	if err := r.consume(ctx, fmt.Sprintf("auth:oauth:code:%s", codeHash), code); err != nil {
+		if err == redis.Nil {
+			return nil, domain.ErrAuthorizationCodeNotFound
+		}
+		return nil, err
+	}
+	return code, nil
+}
+
+func (r *RedisAuthorizationCodeRepo) store(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := r.client.Set(ctx, key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store authorization state in redis: %w", err)
+	}
+	return nil
+}
+
+// consume returns redis.Nil unwrapped when the key does not exist.
+func (r *RedisAuthorizationCodeRepo) consume(ctx context.Context, key string, v interface{}) error {
+	data, err := r.client.GetDel(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return err
+		}
+		return fmt.Errorf("redis error: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("corrupt authorization state: %w", err)
+	}
+	return nil
+}
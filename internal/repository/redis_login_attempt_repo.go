@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLoginAttemptRepo implements domain.LoginAttemptRepository using Redis.
+type RedisLoginAttemptRepo struct {
+	client *redis.Client
+}
+
+// NewRedisLoginAttemptRepo creates a new repository instance.
+func NewRedisLoginAttemptRepo(client *redis.Client) *RedisLoginAttemptRepo {
+	return &RedisLoginAttemptRepo{client: client}
+}
+
+// RecordFailure increments "auth:failures:<key>". The TTL is only set on the first
+// failure (EXPIRE NX), so the window is fixed rather than sliding with every attempt.
+func (r *RedisLoginAttemptRepo) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	k := fmt.Sprintf("auth:failures:%s", key)
+
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, k)
+		pipe.ExpireNX(ctx, k, window)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("redis error: %w", err)
+	}
+
+	return int(incr.Val()), nil
+}
+
+// ResetFailures clears the failure counter, e.g. after a successful login.
+func (r *RedisLoginAttemptRepo) ResetFailures(ctx context.Context, key string) error {
+	return r.client.Del(ctx, fmt.Sprintf("auth:failures:%s", key)).Err()
+}
+
+// Lock blocks key for ttl. The key pattern is "auth:lock:<key>".
+func (r *RedisLoginAttemptRepo) Lock(ctx context.Context, key string, ttl time.Duration) error {
+	return r.client.Set(ctx, fmt.Sprintf("auth:lock:%s", key), 1, ttl).Err()
+}
+
+// LockedFor returns how long key stays locked.
+func (r *RedisLoginAttemptRepo) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, fmt.Sprintf("auth:lock:%s", key)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("redis error: %w", err)
+	}
+
+	// PTTL returns negative values when the key does not exist or has no expiry.
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// Unlock lifts a lock before it expires.
+func (r *RedisLoginAttemptRepo) Unlock(ctx context.Context, key string) error {
+	return r.client.Del(ctx, fmt.Sprintf("auth:lock:%s", key)).Err()
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+)
+
+// reserveAttemptScript bumps the attempt counter only if the challenge still exists,
+// so a late increment cannot resurrect an expired challenge without a TTL.
+//
+// KEYS[1] = auth:mfa:challenge:<token>
+var reserveAttemptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return false
+end
+return redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+`)
+
+// RedisMFAChallengeRepo implements domain.MFAChallengeRepository using Redis.
+type RedisMFAChallengeRepo struct {
+	client *redis.Client
+}
+
+// NewRedisMFAChallengeRepo creates a new repository instance.
+func NewRedisMFAChallengeRepo(client *redis.Client) *RedisMFAChallengeRepo {
+	return &RedisMFAChallengeRepo{client: client}
+}
+
+// StoreMFAChallenge saves a pending challenge with a short Time-To-Live (TTL).
+// The key pattern is "auth:mfa:challenge:<token>" -> hash {user_id, attempts}.
+func (r *RedisMFAChallengeRepo) StoreMFAChallenge(ctx context.Context, token string, userID string, ttl time.Duration) error {
+	key := fmt.Sprintf("auth:mfa:challenge:%s", token)
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", userID, "attempts", 0)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store mfa challenge in redis: %w", err)
+	}
+
+	return nil
+}
+
+// GetMFAChallenge returns the pending challenge bound to the token.
+func (r *RedisMFAChallengeRepo) GetMFAChallenge(ctx context.Context, token string) (*domain.MFAChallenge, error) {
+	key := fmt.Sprintf("auth:mfa:challenge:%s", token)
+
+	fields, err := r.client.HMGet(ctx, key, "user_id", "attempts").Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis error: %w", err)
+	}
+
+	userID, ok := fields[0].(string)
+	if !ok {
+		return nil, domain.ErrMFAChallengeNotFound
+	}
+	attempts, _ := strconv.Atoi(fmt.Sprint(fields[1]))
+
+	return &domain.MFAChallenge{UserID: userID, Attempts: attempts}, nil
+}
+
+// ReserveMFAChallengeAttempt atomically bumps the attempt counter. Callers reserve the
+// attempt before verifying, so concurrent requests each see a distinct count.
+func (r *RedisMFAChallengeRepo) ReserveMFAChallengeAttempt(ctx context.Context, token string) (int, error) {
+	key := fmt.Sprintf("auth:mfa:challenge:%s", token)
+
+	attempts, err := reserveAttemptScript.Run(ctx, r.client, []string{key}).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, domain.ErrMFAChallengeNotFound
+		}
+		return 0, fmt.Errorf("redis error: %w", err)
+	}
+
+	return int(attempts), nil
+}
+
+// DeleteMFAChallenge removes the challenge. Only one caller can observe true.
+func (r *RedisMFAChallengeRepo) DeleteMFAChallenge(ctx context.Context, token string) (bool, error) {
+	key := fmt.Sprintf("auth:mfa:challenge:%s", token)
+
+	deleted, err := r.client.Del(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis error: %w", err)
+	}
+
+	return deleted == 1, nil
+}
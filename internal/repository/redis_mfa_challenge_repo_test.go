@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+)
+
+func TestReserveMFAChallengeAttempt(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisMFAChallengeRepo(newTestRedis(t))
+
+	if _, err := repo.ReserveMFAChallengeAttempt(ctx, "missing"); !errors.Is(err, domain.ErrMFAChallengeNotFound) {
+		t.Fatalf("missing challenge: err = %v, want ErrMFAChallengeNotFound", err)
+	}
+
+	if err := repo.StoreMFAChallenge(ctx, "challenge", "user-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent reservations each get a distinct count
+	const n = 20
+	seen := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempts, err := repo.ReserveMFAChallengeAttempt(ctx, "challenge")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			seen <- attempts
+		}()
+	}
+	wg.Wait()
+	close(seen)
+
+	counts := map[int]bool{}
+	for attempts := range seen {
+		if counts[attempts] {
+			t.Fatalf("attempt %d reserved twice", attempts)
+		}
+		counts[attempts] = true
+	}
+	if len(counts) != n {
+		t.Fatalf("got %d distinct attempts, want %d", len(counts), n)
+	}
+
+	challenge, err := repo.GetMFAChallenge(ctx, "challenge")
+	if err != nil || challenge.UserID != "user-1" || challenge.Attempts != n {
+		t.Fatalf("GetMFAChallenge = %+v, %v", challenge, err)
+	}
+
+	// Deleting is single-winner, and a deleted challenge cannot be revived
+	if deleted, _ := repo.DeleteMFAChallenge(ctx, "challenge"); !deleted {
+		t.Fatal("first delete reported false")
+	}
+	if deleted, _ := repo.DeleteMFAChallenge(ctx, "challenge"); deleted {
+		t.Fatal("second delete reported true")
+	}
+	if _, err := repo.ReserveMFAChallengeAttempt(ctx, "challenge"); !errors.Is(err, domain.ErrMFAChallengeNotFound) {
+		t.Fatalf("deleted challenge: err = %v, want ErrMFAChallengeNotFound", err)
+	}
+}
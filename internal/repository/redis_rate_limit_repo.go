@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript keeps one sorted-set member per request, scored by its arrival
+// time in milliseconds. Entries older than the window are trimmed before counting, so
+// the limit applies to any window-long period rather than to fixed buckets.
+// Redis' own clock is used so replicas with skewed clocks share the same window.
+// Returns {allowed, remaining, reset_ms}.
+var slidingWindowScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, now .. "-" .. ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = window
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, limit - count, reset}
+`)
+
+// RedisRateLimitRepo implements domain.RateLimitRepository using Redis.
+type RedisRateLimitRepo struct {
+	client *redis.Client
+}
+
+// NewRedisRateLimitRepo creates a new repository instance.
+func NewRedisRateLimitRepo(client *redis.Client) *RedisRateLimitRepo {
+	return &RedisRateLimitRepo{client: client}
+}
+
+// Allow counts the request against "auth:ratelimit:<key>" with a sliding window log.
+func (r *RedisRateLimitRepo) Allow(ctx context.Context, key string, limit int, window time.Duration) (*domain.RateLimitResult, error) {
+	// Random suffix keeps concurrent requests in the same millisecond distinct
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	res, err := slidingWindowScript.Run(ctx, r.client,
+		[]string{fmt.Sprintf("auth:ratelimit:%s", key)},
+		window.Milliseconds(), limit, hex.EncodeToString(nonce),
+	).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("redis error: %w", err)
+	}
+
+	return &domain.RateLimitResult{
+		Allowed:   res[0] == 1,
+		Limit:     limit,
+		Remaining: int(res[1]),
+		Reset:     time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
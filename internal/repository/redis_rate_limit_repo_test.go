@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRateLimitSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := NewRedisRateLimitRepo(client)
+
+	start := time.Now()
+	mr.SetTime(start)
+
+	for i := 1; i <= 3; i++ {
+		res, err := repo.Allow(ctx, "login:ip:198.51.100.7", 3, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != 3-i || res.Limit != 3 {
+			t.Fatalf("request %d: %+v", i, res)
+		}
+		mr.SetTime(start.Add(time.Duration(i) * 10 * time.Second))
+	}
+
+	// The budget is spent until the oldest request leaves the window, 30s from now
+	res, err := repo.Allow(ctx, "login:ip:198.51.100.7", 3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.Remaining != 0 {
+		t.Fatalf("over the limit: %+v", res)
+	}
+	if res.Reset != 30*time.Second {
+		t.Fatalf("Reset = %v, want 30s", res.Reset)
+	}
+
+	// Other keys have their own budget
+	if res, err := repo.Allow(ctx, "login:ip:203.0.113.1", 3, time.Minute); err != nil || !res.Allowed {
+		t.Fatalf("other key: %+v, %v", res, err)
+	}
+
+	// Sliding, not fixed: only the first request has left the window
+	mr.SetTime(start.Add(time.Minute + time.Millisecond))
+	res, err = repo.Allow(ctx, "login:ip:198.51.100.7", 3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after the oldest request expired: %+v", res)
+	}
+	if res, _ := repo.Allow(ctx, "login:ip:198.51.100.7", 3, time.Minute); res.Allowed {
+		t.Fatalf("second request after expiry: %+v", res)
+	}
+
+	// The counter itself expires once the window has passed without traffic
+	mr.FastForward(time.Minute)
+	if mr.Exists("auth:ratelimit:login:ip:198.51.100.7") {
+		t.Fatal("idle counter was not expired")
+	}
+}
@@ -6,8 +6,63 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
 )
 
+// consumeRefreshScript atomically swaps a live refresh token for a tombstone.
+// The tombstone keeps the owner, family and client until the token would have expired,
+// which is what lets us recognise a replayed (already rotated) token. Tombstones live
+// outside the "auth:refresh:" namespace, so no token string can address one as live.
+//
+// KEYS[1] = auth:refresh:<token>, KEYS[2] = auth:refresh-used:<token>
+var consumeRefreshScript = redis.NewScript(`
+local live = redis.call('HMGET', KEYS[1], 'user_id', 'family_id', 'client_id')
+if live[1] then
+	local client = live[3] or ''
+	local ttl = redis.call('PTTL', KEYS[1])
+	redis.call('DEL', KEYS[1])
+	redis.call('HSET', KEYS[2], 'user_id', live[1], 'family_id', live[2], 'client_id', client)
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[2], ttl)
+	end
+	return {'ok', live[1], live[2], client}
+end
+local used = redis.call('HMGET', KEYS[2], 'user_id', 'family_id', 'client_id')
+if used[1] then
+	return {'reused', used[1], used[2], used[3] or ''}
+end
+return false
+`)
+
+// revokeFamilyScript deletes the live token of a family together with the family pointer.
+//
+// KEYS[1] = auth:family:<familyID>
+var revokeFamilyScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current then
+	redis.call('DEL', 'auth:refresh:' .. current)
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// revokeUserScript revokes every family listed in the user's index, then drops the index.
+//
+// KEYS[1] = auth:user:<userID>:families
+var revokeUserScript = redis.NewScript(`
+local families = redis.call('SMEMBERS', KEYS[1])
+for _, family in ipairs(families) do
+	local current = redis.call('GET', 'auth:family:' .. family)
+	if current then
+		redis.call('DEL', 'auth:refresh:' .. current)
+	end
+	redis.call('DEL', 'auth:family:' .. family)
+end
+redis.call('DEL', KEYS[1])
+return #families
+`)
+
 // RedisTokenRepo implements domain.TokenRepository using Redis.
 type RedisTokenRepo struct {
 	client *redis.Client
@@ -19,37 +74,181 @@ func NewRedisTokenRepo(client *redis.Client) *RedisTokenRepo {
 }
 
 // StoreRefreshToken saves an opaque token in Redis with a specific Time-To-Live (TTL).
-// The key pattern is "auth:refresh:<token>" -> value "userID".
-func (r *RedisTokenRepo) StoreRefreshToken(ctx context.Context, userID string, token string, ttl time.Duration) error {
+// The key pattern is "auth:refresh:<token>" -> hash {user_id, family_id, client_id},
+// "auth:family:<familyID>" always points at the single live token of the family,
+// and "auth:user:<userID>:families" indexes the families owned by a user.
+func (r *RedisTokenRepo) StoreRefreshToken(ctx context.Context, session domain.RefreshSession, token string, ttl time.Duration) error {
 	key := fmt.Sprintf("auth:refresh:%s", token)
-	
-	// We store the userID as the value so we can identify who owns the token later.
-	err := r.client.Set(ctx, key, userID, ttl).Err()
+	familyKey := fmt.Sprintf("auth:family:%s", session.FamilyID)
+	userKey := fmt.Sprintf("auth:user:%s:families", session.UserID)
+
+	// We store the userID so we can identify who owns the token later.
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", session.UserID, "family_id", session.FamilyID, "client_id", session.ClientID)
+		pipe.Expire(ctx, key, ttl)
+		pipe.Set(ctx, familyKey, token, ttl)
+		pipe.SAdd(ctx, userKey, session.FamilyID)
+		pipe.Expire(ctx, userKey, ttl)
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to store token in redis: %w", err)
 	}
-	
+
 	return nil
 }
 
 // GetUserIDByRefreshToken validates if a refresh token exists and returns the associated User ID.
 func (r *RedisTokenRepo) GetUserIDByRefreshToken(ctx context.Context, token string) (string, error) {
 	key := fmt.Sprintf("auth:refresh:%s", token)
-	
-	userID, err := r.client.Get(ctx, key).Result()
+
+	userID, err := r.client.HGet(ctx, key, "user_id").Result()
 	if err != nil {
 		if err == redis.Nil {
-			return "", fmt.Errorf("refresh token expired or invalid")
+			return "", domain.ErrRefreshTokenNotFound
 		}
 		return "", fmt.Errorf("redis error: %w", err)
 	}
-	
+
 	return userID, nil
 }
 
+// GetRefreshSession returns the owner, family, client and expiry of a live refresh token.
+func (r *RedisTokenRepo) GetRefreshSession(ctx context.Context, token string) (*domain.RefreshSession, error) {
+	key := fmt.Sprintf("auth:refresh:%s", token)
+
+	var fields *redis.SliceCmd
+	var ttl *redis.DurationCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		fields = pipe.HMGet(ctx, key, "user_id", "family_id", "client_id")
+		ttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("redis error: %w", err)
+	}
+
+	values := fields.Val()
+	userID, _ := values[0].(string)
+	familyID, _ := values[1].(string)
+	clientID, _ := values[2].(string)
+	if userID == "" || ttl.Val() <= 0 {
+		return nil, domain.ErrRefreshTokenNotFound
+	}
+
+	return &domain.RefreshSession{
+		UserID:    userID,
+		FamilyID:  familyID,
+		ClientID:  clientID,
+		ExpiresAt: time.Now().Add(ttl.Val()),
+	}, nil
+}
+
+// ConsumeRefreshToken invalidates a token in a single round-trip.
+// Because the check-and-delete runs as a Lua script, two concurrent refreshes
+// with the same token can never both succeed: the loser sees the tombstone.
+func (r *RedisTokenRepo) ConsumeRefreshToken(ctx context.Context, token string) (*domain.RefreshSession, error) {
+	keys := []string{
+		fmt.Sprintf("auth:refresh:%s", token),
+		fmt.Sprintf("auth:refresh-used:%s", token),
+	}
+
+	res, err := consumeRefreshScript.Run(ctx, r.client, keys).StringSlice()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, domain.ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("redis error: %w", err)
+	}
+
+	session := &domain.RefreshSession{UserID: res[1], FamilyID: res[2], ClientID: res[3]}
+	if res[0] == "reused" {
+		return session, domain.ErrRefreshTokenReused
+	}
+
+	return session, nil
+}
+
+// RevokeTokenFamily invalidates every token descending from the same login.
+func (r *RedisTokenRepo) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	familyKey := fmt.Sprintf("auth:family:%s", familyID)
+	return revokeFamilyScript.Run(ctx, r.client, []string{familyKey}).Err()
+}
+
+// ListTokenFamilies returns the IDs of the refresh token families issued to a user.
+// Entries whose family has already expired are pruned from the index on the way.
+func (r *RedisTokenRepo) ListTokenFamilies(ctx context.Context, userID string) ([]string, error) {
+	userKey := fmt.Sprintf("auth:user:%s:families", userID)
+
+	families, err := r.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis error: %w", err)
+	}
+
+	live := make([]string, 0, len(families))
+	for _, family := range families {
+		exists, err := r.client.Exists(ctx, fmt.Sprintf("auth:family:%s", family)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("redis error: %w", err)
+		}
+		if exists == 0 {
+			r.client.SRem(ctx, userKey, family)
+			continue
+		}
+		live = append(live, family)
+	}
+
+	return live, nil
+}
+
+// RevokeAllUserTokens deletes every refresh token the user currently holds.
+func (r *RedisTokenRepo) RevokeAllUserTokens(ctx context.Context, userID string) error {
+	userKey := fmt.Sprintf("auth:user:%s:families", userID)
+	return revokeUserScript.Run(ctx, r.client, []string{userKey}).Err()
+}
+
+// SetTokensValidAfter stores the global logout cut-off for a user as a Unix timestamp.
+func (r *RedisTokenRepo) SetTokensValidAfter(ctx context.Context, userID string, t time.Time, ttl time.Duration) error {
+	key := fmt.Sprintf("auth:user:%s:valid_after", userID)
+	return r.client.Set(ctx, key, t.Unix(), ttl).Err()
+}
+
+// GetTokensValidAfter returns the global logout cut-off, or the zero time if none is set.
+func (r *RedisTokenRepo) GetTokensValidAfter(ctx context.Context, userID string) (time.Time, error) {
+	key := fmt.Sprintf("auth:user:%s:valid_after", userID)
+
+	unix, err := r.client.Get(ctx, key).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("redis error: %w", err)
+	}
+
+	return time.Unix(unix, 0), nil
+}
+
+// DenyAccessToken adds a revoked access token to the denylist. The key
+// "auth:denylist:<jti>" expires together with the token, so the list never grows stale.
+func (r *RedisTokenRepo) DenyAccessToken(ctx context.Context, jti string, ttl time.Duration) error {
+	key := fmt.Sprintf("auth:denylist:%s", jti)
+	return r.client.Set(ctx, key, 1, ttl).Err()
+}
+
+// IsAccessTokenDenied reports whether an access token was revoked individually.
+func (r *RedisTokenRepo) IsAccessTokenDenied(ctx context.Context, jti string) (bool, error) {
+	key := fmt.Sprintf("auth:denylist:%s", jti)
+
+	n, err := r.client.Exists(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis error: %w", err)
+	}
+	return n > 0, nil
+}
+
 // DeleteRefreshToken removes a token immediately.
 // This is used for "Logout" or when a token is rotated.
 func (r *RedisTokenRepo) DeleteRefreshToken(ctx context.Context, token string) error {
 	key := fmt.Sprintf("auth:refresh:%s", token)
 	return r.client.Del(ctx, key).Err()
-}
\ No newline at end of file
+}
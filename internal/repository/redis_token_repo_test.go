@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+)
+
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestConsumeRefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisTokenRepo(newTestRedis(t))
+
+	session := domain.RefreshSession{UserID: "user-1", FamilyID: "family-1", ClientID: "client-1"}
+	if err := repo.StoreRefreshToken(ctx, session, "token-a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	live, err := repo.GetRefreshSession(ctx, "token-a")
+	if err != nil || live.ClientID != "client-1" {
+		t.Fatalf("GetRefreshSession = %+v, %v", live, err)
+	}
+
+	got, err := repo.ConsumeRefreshToken(ctx, "token-a")
+	if err != nil {
+		t.Fatalf("first redemption: %v", err)
+	}
+	if got.UserID != "user-1" || got.FamilyID != "family-1" || got.ClientID != "client-1" {
+		t.Fatalf("session = %+v", got)
+	}
+
+	// A second redemption is a replay and names the family to revoke
+	got, err = repo.ConsumeRefreshToken(ctx, "token-a")
+	if !errors.Is(err, domain.ErrRefreshTokenReused) {
+		t.Fatalf("replay: err = %v, want ErrRefreshTokenReused", err)
+	}
+	if got == nil || got.FamilyID != "family-1" || got.ClientID != "client-1" {
+		t.Fatalf("replay session = %+v", got)
+	}
+
+	if _, err := repo.ConsumeRefreshToken(ctx, "unknown"); !errors.Is(err, domain.ErrRefreshTokenNotFound) {
+		t.Fatalf("unknown token: err = %v, want ErrRefreshTokenNotFound", err)
+	}
+}
+
+func TestConsumeRefreshTokenTombstoneIsNotLive(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisTokenRepo(newTestRedis(t))
+
+	session := domain.RefreshSession{UserID: "user-1", FamilyID: "family-1"}
+	if err := repo.StoreRefreshToken(ctx, session, "token-a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ConsumeRefreshToken(ctx, "token-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, forged := range []string{"used:token-a", "-used:token-a", "used:used:token-a"} {
+		if _, err := repo.ConsumeRefreshToken(ctx, forged); !errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			t.Errorf("%q: err = %v, want ErrRefreshTokenNotFound", forged, err)
+		}
+		if _, err := repo.GetRefreshSession(ctx, forged); !errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			t.Errorf("%q: GetRefreshSession err = %v, want ErrRefreshTokenNotFound", forged, err)
+		}
+	}
+}
+
+func TestRevokeTokenFamily(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisTokenRepo(newTestRedis(t))
+
+	// token-a was rotated into token-b; revoking the family kills token-b
+	session := domain.RefreshSession{UserID: "user-1", FamilyID: "family-1"}
+	if err := repo.StoreRefreshToken(ctx, session, "token-a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ConsumeRefreshToken(ctx, "token-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.StoreRefreshToken(ctx, session, "token-b", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.RevokeTokenFamily(ctx, "family-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ConsumeRefreshToken(ctx, "token-b"); !errors.Is(err, domain.ErrRefreshTokenNotFound) {
+		t.Fatalf("revoked token: err = %v, want ErrRefreshTokenNotFound", err)
+	}
+}
+
+func TestRevokeAllUserTokens(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisTokenRepo(newTestRedis(t))
+
+	for _, s := range []struct{ family, token string }{{"family-1", "token-a"}, {"family-2", "token-b"}} {
+		session := domain.RefreshSession{UserID: "user-1", FamilyID: s.family}
+		if err := repo.StoreRefreshToken(ctx, session, s.token, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := domain.RefreshSession{UserID: "user-2", FamilyID: "family-3"}
+	if err := repo.StoreRefreshToken(ctx, other, "token-c", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := repo.ListTokenFamilies(ctx, "user-1")
+	if err != nil || len(families) != 2 {
+		t.Fatalf("ListTokenFamilies = %v, %v", families, err)
+	}
+
+	if err := repo.RevokeAllUserTokens(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"token-a", "token-b"} {
+		if _, err := repo.GetRefreshSession(ctx, token); !errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			t.Errorf("%s: err = %v, want ErrRefreshTokenNotFound", token, err)
+		}
+	}
+	if _, err := repo.GetRefreshSession(ctx, "token-c"); err != nil {
+		t.Errorf("other user's token was revoked: %v", err)
+	}
+}
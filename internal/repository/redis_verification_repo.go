@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisVerificationRepo implements domain.VerificationTokenRepository using Redis.
+type RedisVerificationRepo struct {
+	client *redis.Client
+}
+
+// NewRedisVerificationRepo creates a new repository instance.
+func NewRedisVerificationRepo(client *redis.Client) *RedisVerificationRepo {
+	return &RedisVerificationRepo{client: client}
+}
+
+// StoreVerificationToken saves an email verification token with a Time-To-Live (TTL).
+// The key pattern is "auth:verify:<token>" -> value "userID".
+func (r *RedisVerificationRepo) StoreVerificationToken(ctx context.Context, userID string, token string, ttl time.Duration) error {
+	key := fmt.Sprintf("auth:verify:%s", token)
+
+	err := r.client.Set(ctx, key, userID, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to store verification token in redis: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeVerificationToken returns the User ID bound to the token and deletes it.
+// GETDEL is atomic, so concurrent redemptions of the same token cannot both succeed.
+func (r *RedisVerificationRepo) ConsumeVerificationToken(ctx context.Context, token string) (string, error) {
+	key := fmt.Sprintf("auth:verify:%s", token)
+
+	userID, err := r.client.GetDel(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", fmt.Errorf("verification token expired or invalid")
+		}
+		return "", fmt.Errorf("redis error: %w", err)
+	}
+
+	return userID, nil
+}
+
+// StorePasswordResetToken saves a reset token hash with a TTL.
+// The key pattern is "auth:reset:<hash>" -> value "userID"; "auth:reset:user:<userID>"
+// points at the latest hash so requesting a new link revokes the previous one.
+func (r *RedisVerificationRepo) StorePasswordResetToken(ctx context.Context, userID string, tokenHash string, ttl time.Duration) error {
+	userKey := fmt.Sprintf("auth:reset:user:%s", userID)
+
+	previous, err := r.client.Get(ctx, userKey).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("redis error: %w", err)
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if previous != "" {
+			pipe.Del(ctx, fmt.Sprintf("auth:reset:%s", previous))
+		}
+		pipe.Set(ctx, fmt.Sprintf("auth:reset:%s", tokenHash), userID, ttl)
+		pipe.Set(ctx, userKey, tokenHash, ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store password reset token in redis: %w", err)
+	}
+
+	return nil
+}
+
+// GetPasswordResetToken returns the User ID bound to the token hash, leaving it in place.
+func (r *RedisVerificationRepo) GetPasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	userID, err := r.client.Get(ctx, fmt.Sprintf("auth:reset:%s", tokenHash)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", fmt.Errorf("password reset token expired or invalid")
+		}
+		return "", fmt.Errorf("redis error: %w", err)
+	}
+
+	return userID, nil
+}
+
+// ConsumePasswordResetToken returns the User ID bound to the token hash and deletes it.
+func (r *RedisVerificationRepo) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	key := fmt.Sprintf("auth:reset:%s", tokenHash)
+
+	userID, err := r.client.GetDel(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", fmt.Errorf("password reset token expired or invalid")
+		}
+		return "", fmt.Errorf("redis error: %w", err)
+	}
+
+	return userID, nil
+}
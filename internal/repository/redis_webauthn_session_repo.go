@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+)
+
+// RedisWebAuthnSessionRepo implements domain.WebAuthnSessionRepository using Redis.
+type RedisWebAuthnSessionRepo struct {
+	client *redis.Client
+}
+
+// NewRedisWebAuthnSessionRepo creates a new repository instance.
+func NewRedisWebAuthnSessionRepo(client *redis.Client) *RedisWebAuthnSessionRepo {
+	return &RedisWebAuthnSessionRepo{client: client}
+}
+
+// StoreWebAuthnSession saves ceremony state with a short Time-To-Live (TTL).
+// The key pattern is "auth:webauthn:<key>" -> JSON session data.
+func (r *RedisWebAuthnSessionRepo) StoreWebAuthnSession(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	err := r.client.Set(ctx, fmt.Sprintf("auth:webauthn:%s", key), data, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to store webauthn session in redis: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeWebAuthnSession atomically reads and deletes the ceremony state (GETDEL).
+func (r *RedisWebAuthnSessionRepo) ConsumeWebAuthnSession(ctx context.Context, key string) ([]byte, error) {
+	data, err := r.client.GetDel(ctx, fmt.Sprintf("auth:webauthn:%s", key)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, domain.ErrWebAuthnSessionNotFound
+		}
+		return nil, fmt.Errorf("redis error: %w", err)
+	}
+
+	return data, nil
+}
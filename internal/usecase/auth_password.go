@@ -0,0 +1,317 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+var (
+	ErrPasswordReused  = errors.New("password was used recently, choose a different one")
+	ErrPasswordChanged = errors.New("password was changed by another request")
+)
+
+// PasswordPolicyError lists every rule of security.DefaultPasswordPolicy a new password failed.
+type PasswordPolicyError struct {
+	Violations []security.PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string { return "password does not meet the password policy" }
+
+// passwordResetTokenTTL bounds how long a reset link stays valid.
+const passwordResetTokenTTL = 30 * time.Minute
+
+// ForgotPassword emails a single-use reset link to the account owner. Only the SHA-256
+// of the token is stored. Unknown addresses are silently ignored, and the email is sent
+// in the background, so the response does not reveal whether the account exists.
+func (u *AuthUsecase) ForgotPassword(ctx context.Context, email, ip string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	user, err := u.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		_ = u.userRepo.LogSecurityEvent(ctx, "", "PASSWORD_RESET_REQUESTED", ip, map[string]interface{}{
+			"email": email,
+		})
+		return nil
+	}
+
+	token, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := u.verifyRepo.StorePasswordResetToken(ctx, user.ID, security.HashOpaqueToken(token), passwordResetTokenTTL); err != nil {
+		return err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "PASSWORD_RESET_REQUESTED", ip, nil)
+
+	u.sendInBackground(ctx, func(ctx context.Context) error {
+		return u.mailer.SendPasswordResetEmail(ctx, user.Email, token)
+	})
+	return nil
+}
+
+// ResetPassword redeems a reset token and sets a new password. Every existing session
+// of the user is revoked, since the old password may have been compromised.
+// The token is only consumed once the new password is accepted and hashed, so a rejected
+// password or a busy hashing pool does not burn the link.
+func (u *AuthUsecase) ResetPassword(ctx context.Context, token, newPassword, ip string) error {
+	tokenHash := security.HashOpaqueToken(token)
+
+	userID, err := u.verifyRepo.GetPasswordResetToken(ctx, tokenHash)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	if err := u.checkNewPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+	hash, err := security.HashPassword(newPassword)
+	if err != nil {
+		return busyError(err)
+	}
+
+	// Consume atomically; a concurrent redemption of the same link loses here
+	if owner, err := u.verifyRepo.ConsumePasswordResetToken(ctx, tokenHash); err != nil || owner != user.ID {
+		return ErrInvalidToken
+	}
+
+	// The link is spent now, so a password change that landed since user was read
+	// does not fail the reset: it is overwritten like any other old password.
+	err = u.storePassword(ctx, user, hash)
+	if errors.Is(err, ErrPasswordChanged) {
+		if user, err = u.userRepo.GetByID(ctx, userID); err == nil {
+			err = u.storePassword(ctx, user, hash)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Redeeming a link sent to the inbox also proves ownership of the address
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		if err := u.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	if err := u.revokeAllSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	u.clearFailures(ctx, user.Email)
+	u.flagBreachedPassword(ctx, user.ID, newPassword, ip)
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "PASSWORD_CHANGED", ip, map[string]interface{}{
+		"method": "reset",
+	})
+
+	return nil
+}
+
+// ChangePassword replaces the password of an authenticated user. The current password,
+// and a TOTP code when MFA is enabled, must be supplied. All other sessions are revoked;
+// the caller receives a fresh token pair to stay signed in.
+func (u *AuthUsecase) ChangePassword(ctx context.Context, userID, currentPassword, newPassword, code, ip string) (*domain.AuthResponse, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	// A stolen session must not be usable to brute-force the current password
+	if err := u.checkThrottle(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	match, err := security.ComparePassword(currentPassword, user.PasswordHash)
+	if errors.Is(err, security.ErrHashPoolBusy) {
+		return nil, busyError(err)
+	}
+	if err != nil || !match {
+		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "PASSWORD_CHANGE_FAILED", ip, map[string]interface{}{
+			"reason": "invalid_password",
+		})
+		u.registerFailure(ctx, user.ID, user.Email, ip)
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.MFAEnabled {
+		if ok, err := u.verifyTOTP(ctx, user, code); err != nil || !ok {
+			_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "PASSWORD_CHANGE_FAILED", ip, map[string]interface{}{
+				"reason": "invalid_mfa_code",
+			})
+			u.registerFailure(ctx, user.ID, user.Email, ip)
+			return nil, ErrInvalidMFACode
+		}
+	}
+
+	if err := u.checkNewPassword(ctx, user, newPassword); err != nil {
+		return nil, err
+	}
+	if err := u.setPassword(ctx, user, newPassword); err != nil {
+		return nil, err
+	}
+
+	resp, err := u.replaceSessions(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	u.flagBreachedPassword(ctx, user.ID, newPassword, ip)
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "PASSWORD_CHANGED", ip, map[string]interface{}{
+		"method": "change",
+	})
+
+	return resp, nil
+}
+
+// checkNewPassword validates a candidate password against the password policy and
+// refuses the current one and the last PasswordHistory-1 retired ones.
+func (u *AuthUsecase) checkNewPassword(ctx context.Context, user *domain.User, password string) error {
+	if violations := security.DefaultPasswordPolicy.Check(password, user.Email); len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	previous := []string{user.PasswordHash}
+	if u.cfg.PasswordHistory > 1 {
+		history, err := u.userRepo.GetPasswordHistory(ctx, user.ID, u.cfg.PasswordHistory-1)
+		if err != nil {
+			return err
+		}
+		previous = append(previous, history...)
+	}
+
+	for _, hash := range previous {
+		match, err := security.ComparePassword(password, hash)
+		if errors.Is(err, security.ErrHashPoolBusy) {
+			return busyError(err)
+		}
+		if match {
+			return ErrPasswordReused
+		}
+	}
+
+	return nil
+}
+
+// flagBreachedPassword audits a password that was accepted although it appears in the
+// breach corpus, which happens when the policy flags instead of rejecting.
+func (u *AuthUsecase) flagBreachedPassword(ctx context.Context, userID, password, ip string) {
+	policy := &security.DefaultPasswordPolicy
+	if policy.RejectBreached {
+		return
+	}
+
+	if count, breached := policy.Breached(password); breached {
+		_ = u.userRepo.LogSecurityEvent(ctx, userID, "BREACHED_PASSWORD", ip, map[string]interface{}{
+			"occurrences": count,
+		})
+	}
+}
+
+// upgradePasswordHash re-hashes a just-verified password when its stored hash uses outdated
+// parameters or a legacy scheme (bcrypt, scrypt, PBKDF2, Django). Failures are ignored:
+// the login itself already succeeded.
+func (u *AuthUsecase) upgradePasswordHash(ctx context.Context, user *domain.User, password string) {
+	if !security.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hash, err := security.HashPassword(password)
+	if err != nil {
+		return
+	}
+
+	// A reset or change that landed since the password was checked must not be undone
+	previous := security.HashScheme(user.PasswordHash)
+	if swapped, err := u.userRepo.UpdatePasswordHash(ctx, user.ID, user.PasswordHash, hash); err != nil || !swapped {
+		return
+	}
+	user.PasswordHash = hash
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "PASSWORD_REHASHED", "", map[string]interface{}{
+		"from":        previous,
+		"memory":      security.DefaultParams.Memory,
+		"iterations":  security.DefaultParams.Iterations,
+		"parallelism": security.DefaultParams.Parallelism,
+		"pepper":      security.DefaultPepper.CurrentID,
+	})
+}
+
+// setPassword hashes and stores the new password, moving the old hash into the history.
+// It fails with ErrPasswordChanged if the stored hash is no longer the one user was read with.
+func (u *AuthUsecase) setPassword(ctx context.Context, user *domain.User, password string) error {
+	hash, err := security.HashPassword(password)
+	if err != nil {
+		return busyError(err)
+	}
+
+	return u.storePassword(ctx, user, hash)
+}
+
+// storePassword swaps in an already computed hash as setPassword does.
+func (u *AuthUsecase) storePassword(ctx context.Context, user *domain.User, hash string) error {
+	retired := user.PasswordHash
+	swapped, err := u.userRepo.UpdatePasswordHash(ctx, user.ID, retired, hash)
+	if err != nil {
+		return err
+	}
+	if !swapped {
+		return ErrPasswordChanged
+	}
+	user.PasswordHash = hash
+
+	if u.cfg.PasswordHistory > 1 && retired != "" {
+		return u.userRepo.AddPasswordHistory(ctx, user.ID, retired, u.cfg.PasswordHistory-1)
+	}
+	return nil
+}
+
+// replaceSessions revokes every session of the user and issues a new one for the caller.
+func (u *AuthUsecase) replaceSessions(ctx context.Context, user *domain.User) (*domain.AuthResponse, error) {
+	if err := u.tokenRepo.RevokeAllUserTokens(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	if err := u.tokenRepo.SetTokensValidAfter(ctx, user.ID, sessionCutoff(), accessTokenTTL); err != nil {
+		return nil, err
+	}
+
+	familyID, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return u.issueTokens(ctx, user, familyID, "")
+}
+
+// PasswordHashStats counts accounts per password hash scheme and how many of them still
+// use a legacy scheme that will be upgraded to Argon2id on their next login.
+func (u *AuthUsecase) PasswordHashStats(ctx context.Context) (map[string]int, int, error) {
+	prefixes, err := u.userRepo.CountPasswordHashPrefixes(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	schemes := make(map[string]int)
+	legacy := 0
+	for prefix, count := range prefixes {
+		scheme := security.HashScheme(prefix)
+		schemes[scheme] += count
+		if scheme != "argon2id" {
+			legacy += count
+		}
+	}
+
+	return schemes, legacy, nil
+}
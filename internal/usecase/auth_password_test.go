@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+// createLegacyUser stores a verified account whose password is a bcrypt hash, which
+// the next successful login upgrades to Argon2id.
+func createLegacyUser(t *testing.T, env *testEnv, email string) string {
+	t.Helper()
+	legacy, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := env.createUser(t, email, testPassword)
+	if ok, _ := env.users.UpdatePasswordHash(context.Background(), user.ID, user.PasswordHash, string(legacy)); !ok {
+		t.Fatal("could not install legacy hash")
+	}
+	return user.ID
+}
+
+// resetMailer hands out the password reset tokens that ForgotPassword sends.
+type resetMailer struct {
+	nopMailer
+	tokens chan string
+}
+
+func (m resetMailer) SendPasswordResetEmail(ctx context.Context, email, token string) error {
+	m.tokens <- token
+	return nil
+}
+
+// requestReset runs ForgotPassword and returns the token from the email.
+func requestReset(t *testing.T, env *testEnv, email string) string {
+	t.Helper()
+	mailer := resetMailer{tokens: make(chan string, 1)}
+	env.auth.mailer = mailer
+	if err := env.auth.ForgotPassword(context.Background(), email, "198.51.100.7"); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	select {
+	case token := <-mailer.tokens:
+		return token
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reset email sent")
+		return ""
+	}
+}
+
+func TestResetPasswordRevokesSessions(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "alice@example.com", testPassword)
+	const newPassword = "a completely different passphrase"
+
+	old, err := env.auth.Login(ctx, user.Email, testPassword, "198.51.100.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// iat has second precision: only tokens from earlier seconds are cut off
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+	token := requestReset(t, env, user.Email)
+
+	// A password the policy refuses leaves the link usable
+	var policy *PasswordPolicyError
+	if err := env.auth.ResetPassword(ctx, token, "short", "198.51.100.7"); !errors.As(err, &policy) {
+		t.Fatalf("weak password: err = %v, want a PasswordPolicyError", err)
+	}
+	if err := env.auth.ResetPassword(ctx, token, newPassword, "198.51.100.7"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	if _, err := env.auth.Authenticate(ctx, old.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("old access token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := env.auth.Refresh(ctx, old.RefreshToken); err == nil {
+		t.Fatal("old refresh token still works")
+	}
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, "198.51.100.7"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("old password: err = %v, want ErrInvalidCredentials", err)
+	}
+	env.skipBackoff()
+	if _, err := env.auth.Login(ctx, user.Email, newPassword, "198.51.100.7"); err != nil {
+		t.Fatalf("new password: %v", err)
+	}
+}
+
+func TestResetPasswordTokenIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "bob@example.com", testPassword)
+
+	token := requestReset(t, env, user.Email)
+	if err := env.auth.ResetPassword(ctx, token, "a completely different passphrase", ""); err != nil {
+		t.Fatalf("first redemption: %v", err)
+	}
+	if err := env.auth.ResetPassword(ctx, token, "yet another different passphrase", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("second redemption: err = %v, want ErrInvalidToken", err)
+	}
+
+	// Neither is a token that was never issued
+	if err := env.auth.ResetPassword(ctx, "not-a-token", "yet another different passphrase", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("unknown token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLoginUpgradesLegacyHash(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	userID := createLegacyUser(t, env, "frank@example.com")
+
+	if _, err := env.auth.Login(ctx, "frank@example.com", testPassword, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := env.users.GetByID(ctx, userID)
+	if security.HashScheme(user.PasswordHash) != "argon2id" {
+		t.Fatalf("hash not upgraded: %s", user.PasswordHash)
+	}
+	if ok, _ := security.ComparePassword(testPassword, user.PasswordHash); !ok {
+		t.Fatal("upgraded hash does not match the password")
+	}
+	if !env.users.hasEvent("PASSWORD_REHASHED") {
+		t.Fatal("upgrade was not audited")
+	}
+}
+
+func TestUpgradePasswordHashKeepsConcurrentChange(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	userID := createLegacyUser(t, env, "grace@example.com")
+
+	// The login read the account, then a reset landed before the rehash was written
+	stale, _ := env.users.GetByID(ctx, userID)
+	fresh, _ := env.users.GetByID(ctx, userID)
+	if err := env.auth.setPassword(ctx, fresh, "a brand new passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	env.auth.upgradePasswordHash(ctx, stale, testPassword)
+
+	stored, _ := env.users.GetByID(ctx, userID)
+	if stored.PasswordHash != fresh.PasswordHash {
+		t.Fatal("rehash overwrote a concurrent password change")
+	}
+	if env.users.hasEvent("PASSWORD_REHASHED") {
+		t.Fatal("lost rehash was audited")
+	}
+}
+
+func TestSetPasswordRejectsStaleSnapshot(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "heidi@example.com", testPassword)
+
+	first, _ := env.users.GetByID(ctx, user.ID)
+	second, _ := env.users.GetByID(ctx, user.ID)
+	if err := env.auth.setPassword(ctx, first, "first new passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.auth.setPassword(ctx, second, "second new passphrase"); !errors.Is(err, ErrPasswordChanged) {
+		t.Fatalf("err = %v, want ErrPasswordChanged", err)
+	}
+
+	stored, _ := env.users.GetByID(ctx, user.ID)
+	if ok, _ := security.ComparePassword("first new passphrase", stored.PasswordHash); !ok {
+		t.Fatal("first change was lost")
+	}
+}
@@ -0,0 +1,131 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+var (
+	ErrAccountLocked   = errors.New("account temporarily locked due to too many failed attempts")
+	ErrTooManyAttempts = errors.New("too many attempts, slow down")
+	ErrServiceBusy     = errors.New("service is busy, try again later")
+)
+
+// maxProgressiveDelay caps the exponential wait between failed attempts.
+const maxProgressiveDelay = time.Minute
+
+// ThrottleError is returned when brute-force protection refuses an attempt.
+// It wraps ErrAccountLocked or ErrTooManyAttempts and tells the client when to retry.
+type ThrottleError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string { return e.Err.Error() }
+func (e *ThrottleError) Unwrap() error { return e.Err }
+
+// BusyError is returned when the password hashing pool could not take the request in time.
+// It wraps ErrServiceBusy; nothing was checked, so the attempt does not count as a failure.
+type BusyError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *BusyError) Error() string { return e.Err.Error() }
+func (e *BusyError) Unwrap() error { return e.Err }
+
+// busyError turns security.ErrHashPoolBusy into a BusyError and passes other errors through.
+func busyError(err error) error {
+	if errors.Is(err, security.ErrHashPoolBusy) {
+		return &BusyError{Err: ErrServiceBusy, RetryAfter: security.DefaultHashPool.RetryAfter()}
+	}
+	return err
+}
+
+// checkThrottle refuses the attempt if the account is locked, still inside its
+// progressive delay, or the client IP has exceeded its failure budget.
+func (u *AuthUsecase) checkThrottle(ctx context.Context, email, ip string) error {
+	if ttl, err := u.attemptRepo.LockedFor(ctx, "account:"+email); err != nil {
+		return err
+	} else if ttl > 0 {
+		return &ThrottleError{Err: ErrAccountLocked, RetryAfter: ttl}
+	}
+
+	if ttl, err := u.attemptRepo.LockedFor(ctx, "delay:"+email); err != nil {
+		return err
+	} else if ttl > 0 {
+		return &ThrottleError{Err: ErrTooManyAttempts, RetryAfter: ttl}
+	}
+
+	if ip != "" {
+		if ttl, err := u.attemptRepo.LockedFor(ctx, "ip:"+ip); err != nil {
+			return err
+		} else if ttl > 0 {
+			return &ThrottleError{Err: ErrTooManyAttempts, RetryAfter: ttl}
+		}
+	}
+
+	return nil
+}
+
+// registerFailure counts a failed password or second-factor attempt against both the
+// account and the IP. Every failure after the first doubles the wait before the next
+// attempt; reaching MaxAccountFailures locks the account for LockoutDuration.
+func (u *AuthUsecase) registerFailure(ctx context.Context, userID, email, ip string) {
+	failures, err := u.attemptRepo.RecordFailure(ctx, "account:"+email, u.cfg.FailureWindow)
+	if err == nil {
+		if failures >= u.cfg.MaxAccountFailures {
+			_ = u.attemptRepo.Lock(ctx, "account:"+email, u.cfg.LockoutDuration)
+			_ = u.attemptRepo.ResetFailures(ctx, "account:"+email)
+			_ = u.userRepo.LogSecurityEvent(ctx, userID, "ACCOUNT_LOCKED", ip, map[string]interface{}{
+				"failures": failures,
+			})
+		} else if failures > 1 {
+			delay := u.cfg.BackoffBase << (failures - 2)
+			if delay <= 0 || delay > maxProgressiveDelay {
+				delay = maxProgressiveDelay
+			}
+			_ = u.attemptRepo.Lock(ctx, "delay:"+email, delay)
+		}
+	}
+
+	if ip == "" {
+		return
+	}
+	failures, err = u.attemptRepo.RecordFailure(ctx, "ip:"+ip, u.cfg.FailureWindow)
+	if err == nil && failures >= u.cfg.MaxIPFailures {
+		_ = u.attemptRepo.Lock(ctx, "ip:"+ip, u.cfg.FailureWindow)
+		_ = u.attemptRepo.ResetFailures(ctx, "ip:"+ip)
+		_ = u.userRepo.LogSecurityEvent(ctx, "", "IP_THROTTLED", ip, map[string]interface{}{
+			"failures": failures,
+		})
+	}
+}
+
+// clearFailures forgets the account's failed attempts after a successful login.
+func (u *AuthUsecase) clearFailures(ctx context.Context, email string) {
+	_ = u.attemptRepo.ResetFailures(ctx, "account:"+email)
+}
+
+// UnlockAccount lifts a lockout before it expires. Used by administrators.
+func (u *AuthUsecase) UnlockAccount(ctx context.Context, adminID, userID string) error {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := u.attemptRepo.Unlock(ctx, "account:"+user.Email); err != nil {
+		return err
+	}
+	_ = u.attemptRepo.Unlock(ctx, "delay:"+user.Email)
+	_ = u.attemptRepo.ResetFailures(ctx, "account:"+user.Email)
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "ACCOUNT_UNLOCKED", "", map[string]interface{}{
+		"admin_id": adminID,
+	})
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+// failLogin makes a login attempt with a wrong password and expects it to be checked.
+func failLogin(t *testing.T, env *testEnv, email, ip string) {
+	t.Helper()
+	if _, err := env.auth.Login(context.Background(), email, "wrong password", ip); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLoginProgressiveDelay(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.auth.cfg.BackoffBase = time.Second
+	user := env.createUser(t, "alice@example.com", testPassword)
+	const ip = "198.51.100.7"
+
+	// The first failure is free; each further one doubles the wait
+	failLogin(t, env, user.Email, ip)
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		failLogin(t, env, user.Email, ip)
+
+		var throttle *ThrottleError
+		_, err := env.auth.Login(ctx, user.Email, testPassword, ip)
+		if !errors.As(err, &throttle) || !errors.Is(err, ErrTooManyAttempts) {
+			t.Fatalf("inside the delay: err = %v, want ErrTooManyAttempts", err)
+		}
+		if throttle.RetryAfter <= 0 || throttle.RetryAfter > want {
+			t.Fatalf("RetryAfter = %v, want at most %v", throttle.RetryAfter, want)
+		}
+		env.redis.FastForward(want)
+	}
+
+	// Once the delay has passed the right password is accepted and the count starts over
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, ip); err != nil {
+		t.Fatalf("after the delay: %v", err)
+	}
+	failLogin(t, env, user.Email, ip)
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, ip); err != nil {
+		t.Fatalf("first failure after a success was delayed: %v", err)
+	}
+}
+
+func TestLoginLocksAccount(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "bob@example.com", testPassword)
+
+	// Spread over several IPs: the lock follows the account, not the client
+	for i := 0; i < env.auth.cfg.MaxAccountFailures; i++ {
+		failLogin(t, env, user.Email, fmt.Sprintf("198.51.100.%d", i+1))
+		env.skipBackoff()
+	}
+	if !env.users.hasEvent("ACCOUNT_LOCKED") {
+		t.Fatal("lockout was not audited")
+	}
+
+	var throttle *ThrottleError
+	_, err := env.auth.Login(ctx, user.Email, testPassword, "203.0.113.1")
+	if !errors.As(err, &throttle) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("locked account: err = %v, want ErrAccountLocked", err)
+	}
+	if throttle.RetryAfter <= 0 || throttle.RetryAfter > env.auth.cfg.LockoutDuration {
+		t.Fatalf("RetryAfter = %v, want at most %v", throttle.RetryAfter, env.auth.cfg.LockoutDuration)
+	}
+
+	env.redis.FastForward(env.auth.cfg.LockoutDuration)
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, "203.0.113.1"); err != nil {
+		t.Fatalf("after the lockout: %v", err)
+	}
+}
+
+func TestLoginThrottlesIP(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.auth.cfg.MaxIPFailures = 3
+	user := env.createUser(t, "carol@example.com", testPassword)
+	const ip = "198.51.100.7"
+
+	// Guessing across many accounts, existing or not, exhausts the IP's budget
+	for _, email := range []string{"nobody@example.com", "someone@example.com", "anyone@example.com"} {
+		failLogin(t, env, email, ip)
+	}
+	if !env.users.hasEvent("IP_THROTTLED") {
+		t.Fatal("IP throttling was not audited")
+	}
+
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, ip); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("throttled IP: err = %v, want ErrTooManyAttempts", err)
+	}
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, "203.0.113.1"); err != nil {
+		t.Fatalf("other IP: %v", err)
+	}
+
+	env.redis.FastForward(env.auth.cfg.FailureWindow)
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, ip); err != nil {
+		t.Fatalf("after the window: %v", err)
+	}
+}
+
+func TestUnlockAccount(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "dave@example.com", testPassword)
+
+	for i := 0; i < env.auth.cfg.MaxAccountFailures; i++ {
+		failLogin(t, env, user.Email, "")
+		env.skipBackoff()
+	}
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, ""); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("locked account: err = %v, want ErrAccountLocked", err)
+	}
+
+	if err := env.auth.UnlockAccount(ctx, "admin-1", user.ID); err != nil {
+		t.Fatalf("UnlockAccount: %v", err)
+	}
+	if !env.users.hasEvent("ACCOUNT_UNLOCKED") {
+		t.Fatal("unlock was not audited")
+	}
+
+	// The failure count was reset too: one more failure does not lock again
+	failLogin(t, env, user.Email, "")
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, ""); err != nil {
+		t.Fatalf("after unlock: %v", err)
+	}
+
+	if err := env.auth.UnlockAccount(ctx, "admin-1", "missing"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("unknown user: err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestLoginShedsLoadWhenHashPoolIsBusy(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "erin@example.com", testPassword)
+
+	pool := security.DefaultHashPool
+	t.Cleanup(func() { security.DefaultHashPool = pool })
+	security.DefaultHashPool = security.NewHashPool(1, 10*time.Millisecond)
+
+	// Hold the only slot
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		_ = security.DefaultHashPool.Do(func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	for i := 0; i < env.auth.cfg.MaxAccountFailures; i++ {
+		var busy *BusyError
+		_, err := env.auth.Login(ctx, user.Email, "wrong password", "198.51.100.7")
+		if !errors.As(err, &busy) || !errors.Is(err, ErrServiceBusy) {
+			t.Fatalf("saturated pool: err = %v, want ErrServiceBusy", err)
+		}
+		if busy.RetryAfter != 10*time.Millisecond {
+			t.Fatalf("RetryAfter = %v, want the queue timeout", busy.RetryAfter)
+		}
+	}
+	close(release)
+
+	// Nothing was checked, so none of the shed attempts counted as a failure
+	if _, err := env.auth.Login(ctx, user.Email, testPassword, "198.51.100.7"); err != nil {
+		t.Fatalf("after the pool drained: %v", err)
+	}
+	if s := security.DefaultHashPool.Stats(); s.Timeouts != uint64(env.auth.cfg.MaxAccountFailures) {
+		t.Fatalf("stats = %+v", s)
+	}
+}
@@ -3,6 +3,9 @@ package usecase
 import (
 	"context"
 	"errors"
+	"log"
+	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
@@ -13,86 +16,843 @@ var (
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrMFARequired        = errors.New("mfa_challenge_required")
 	ErrInvalidMFACode     = errors.New("invalid mfa code")
+	ErrEmailNotVerified   = errors.New("email address not verified")
+	ErrInvalidEmail       = errors.New("invalid email address")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrMFAAlreadyEnabled  = errors.New("mfa is already enabled")
+	ErrMFANotEnabled      = errors.New("mfa is not enabled")
+	ErrMFANotPending      = errors.New("mfa enrollment has not been started")
+	ErrClientToken        = errors.New("token was issued to an oauth client")
 )
 
+const (
+	defaultRole          = "user"
+	verificationTokenTTL = 24 * time.Hour
+	accessTokenTTL       = 15 * time.Minute
+	refreshTokenTTL      = 24 * time.Hour
+	recoveryCodeCount    = 10
+)
+
+// Config holds the tunable policies of the authentication flows.
+// Zero values fall back to sensible defaults in NewAuthUsecase.
+type Config struct {
+	// Keys signs and verifies access tokens. When nil, an HS256 keyring is built from JWTSecret.
+	Keys      *security.Keyring
+	JWTSecret string
+
+	// MFAChallengeTTL bounds the time between the password step and the second factor.
+	MFAChallengeTTL time.Duration
+	// MFAMaxAttempts is the number of wrong codes after which a challenge self-destructs.
+	MFAMaxAttempts int
+
+	// Brute-force protection for passwords and second factors.
+	MaxAccountFailures int           // failures before the account is locked
+	MaxIPFailures      int           // failures from a single IP before it is throttled
+	FailureWindow      time.Duration // how long failures are remembered
+	LockoutDuration    time.Duration // how long a locked account stays locked
+	BackoffBase        time.Duration // wait after the second failure, doubled on each further one
+
+	// PasswordHistory is how many recent passwords (including the current one) cannot be reused.
+	PasswordHistory int
+}
+
 type AuthUsecase struct {
-	userRepo  domain.UserRepository
-	tokenRepo domain.TokenRepository
-	jwtSecret string
+	userRepo      domain.UserRepository
+	tokenRepo     domain.TokenRepository
+	verifyRepo    domain.VerificationTokenRepository
+	challengeRepo domain.MFAChallengeRepository
+	attemptRepo   domain.LoginAttemptRepository
+	mailer        domain.Mailer
+	cfg           Config
 }
 
-func NewAuthUsecase(u domain.UserRepository, t domain.TokenRepository, secret string) *AuthUsecase {
+func NewAuthUsecase(u domain.UserRepository, t domain.TokenRepository, v domain.VerificationTokenRepository, c domain.MFAChallengeRepository, a domain.LoginAttemptRepository, m domain.Mailer, cfg Config) *AuthUsecase {
+	if cfg.MFAChallengeTTL <= 0 {
+		cfg.MFAChallengeTTL = 5 * time.Minute
+	}
+	if cfg.MFAMaxAttempts <= 0 {
+		cfg.MFAMaxAttempts = 5
+	}
+	if cfg.MaxAccountFailures <= 0 {
+		cfg.MaxAccountFailures = 5
+	}
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = 50
+	}
+	if cfg.FailureWindow <= 0 {
+		cfg.FailureWindow = 15 * time.Minute
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = 15 * time.Minute
+	}
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = time.Second
+	}
+	if cfg.PasswordHistory <= 0 {
+		cfg.PasswordHistory = 5
+	}
+	if cfg.Keys == nil {
+		cfg.Keys = security.NewHMACKeyring(cfg.JWTSecret)
+	}
+
 	return &AuthUsecase{
-		userRepo:  u,
-		tokenRepo: t,
-		jwtSecret: secret,
+		userRepo:      u,
+		tokenRepo:     t,
+		verifyRepo:    v,
+		challengeRepo: c,
+		attemptRepo:   a,
+		mailer:        m,
+		cfg:           cfg,
 	}
 }
 
+// Register creates a new, unverified account with the default role and emails a
+// single-use verification token. Login is refused until the token is redeemed.
+// If the address is already registered the caller gets the same result; the owner
+// is emailed instead, so the endpoint cannot be used to probe for accounts.
+func (u *AuthUsecase) Register(ctx context.Context, email, password, ip string) error {
+	// ParseAddress also accepts display names ("Name <a@b.c>"); only the address is kept
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return ErrInvalidEmail
+	}
+	email = strings.ToLower(addr.Address)
+	if violations := security.DefaultPasswordPolicy.Check(password, email); len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	hash, err := security.HashPassword(password)
+	if err != nil {
+		return busyError(err)
+	}
+
+	user := &domain.User{
+		Email:        email,
+		PasswordHash: hash,
+		Role:         defaultRole,
+	}
+	if err := u.userRepo.Create(ctx, user); err != nil {
+		if errors.Is(err, domain.ErrUserAlreadyExists) {
+			u.notifyExistingAccount(ctx, email)
+			return nil
+		}
+		return err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "USER_REGISTERED", ip, nil)
+	u.flagBreachedPassword(ctx, user.ID, password, ip)
+
+	u.sendInBackground(ctx, func(ctx context.Context) error {
+		return u.sendVerification(ctx, user)
+	})
+	return nil
+}
+
+// ResendVerification issues a fresh verification token for an unverified account.
+// Unknown or already verified addresses are silently ignored. The email is sent in
+// the background so the response time is the same in every case.
+func (u *AuthUsecase) ResendVerification(ctx context.Context, email string) error {
+	user, err := u.userRepo.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil || user.EmailVerified {
+		return nil
+	}
+
+	u.sendInBackground(ctx, func(ctx context.Context) error {
+		return u.sendVerification(ctx, user)
+	})
+	return nil
+}
+
+// notifyExistingAccount handles a registration for an address that already has an account.
+// An unverified owner gets a fresh verification link, a verified one a notice that someone
+// tried to sign up with their address.
+func (u *AuthUsecase) notifyExistingAccount(ctx context.Context, email string) {
+	user, err := u.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "REGISTER_EXISTING_EMAIL", "", nil)
+
+	u.sendInBackground(ctx, func(ctx context.Context) error {
+		if !user.EmailVerified {
+			return u.sendVerification(ctx, user)
+		}
+		return u.mailer.SendAccountExistsEmail(ctx, user.Email)
+	})
+}
+
+// sendInBackground delivers an email without making the caller wait, so anonymous
+// endpoints answer in the same time whether or not a message was sent.
+func (u *AuthUsecase) sendInBackground(ctx context.Context, send func(ctx context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := send(ctx); err != nil {
+			log.Printf("background email failed: %v", err)
+		}
+	}()
+}
+
+// VerifyEmail redeems a verification token and marks the owning account as verified.
+func (u *AuthUsecase) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := u.verifyRepo.ConsumeVerificationToken(ctx, token)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	user.EmailVerified = true
+	if err := u.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "EMAIL_VERIFIED", "", nil)
+
+	return nil
+}
+
+// sendVerification stores a new verification token for the user and emails it.
+func (u *AuthUsecase) sendVerification(ctx context.Context, user *domain.User) error {
+	token, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := u.verifyRepo.StoreVerificationToken(ctx, user.ID, token, verificationTokenTTL); err != nil {
+		return err
+	}
+
+	return u.mailer.SendVerificationEmail(ctx, user.Email, token)
+}
+
 // Login handles the first step of authentication: validating credentials.
-func (u *AuthUsecase) Login(ctx context.Context, email, password string) (*domain.AuthResponse, error) {
+// When MFA is enabled it returns ErrMFARequired together with a response that
+// only carries the MFA challenge token.
+func (u *AuthUsecase) Login(ctx context.Context, email, password, ip string) (*domain.AuthResponse, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	// 0. Refuse early while the account or IP is locked out / backing off
+	if err := u.checkThrottle(ctx, email, ip); err != nil {
+		return nil, err
+	}
+
 	user, err := u.userRepo.GetByEmail(ctx, email)
 	if err != nil {
+		// Spend the same Argon2id cost as a real check so timing does not reveal the account
+		if err := security.DummyComparePassword(password); errors.Is(err, security.ErrHashPoolBusy) {
+			return nil, busyError(err)
+		}
+		_ = u.userRepo.LogSecurityEvent(ctx, "", "LOGIN_FAILED", ip, map[string]interface{}{
+			"email": email,
+		})
+		u.registerFailure(ctx, "", email, ip)
 		return nil, ErrInvalidCredentials
 	}
 
 	// 1. Verify Password using Argon2id
 	match, err := security.ComparePassword(password, user.PasswordHash)
+	if errors.Is(err, security.ErrHashPoolBusy) {
+		return nil, busyError(err)
+	}
 	if err != nil || !match {
-		// Log failed attempt if necessary
-		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "LOGIN_FAILED", "", nil)
+		// Log failed attempt and count it towards lockout
+		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "LOGIN_FAILED", ip, nil)
+		u.registerFailure(ctx, user.ID, email, ip)
 		return nil, ErrInvalidCredentials
 	}
 
-	// 2. Check if Multi-Factor Authentication is required
-	if user.MFAEnabled {
-		return nil, ErrMFARequired
+	// Roll the stored hash forward if the Argon2id cost has been raised since it was made
+	u.upgradePasswordHash(ctx, user, password)
+
+	// 2. Accounts must prove ownership of their email before signing in
+	if !user.EmailVerified {
+		return nil, ErrEmailNotVerified
 	}
 
-	// 3. If no MFA, generate the session immediately
+	// 3. Check if Multi-Factor Authentication is required (TOTP and/or passkeys).
+	// The caller receives a challenge token that binds the second step to this one.
+	methods, err := u.mfaMethods(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) > 0 {
+		return u.startMFAChallenge(ctx, user, methods)
+	}
+
+	// 4. If no MFA, generate the session immediately
+	u.clearFailures(ctx, email)
 	return u.generateSession(ctx, user)
 }
 
-// VerifyMFA handles the second step: validating the TOTP code.
-func (u *AuthUsecase) VerifyMFA(ctx context.Context, email, code string) (*domain.AuthResponse, error) {
-	user, err := u.userRepo.GetByEmail(ctx, email)
+// VerifyMFA handles the second step: validating the TOTP (or recovery) code against
+// the challenge issued by Login. The challenge is destroyed after MFAMaxAttempts wrong codes.
+func (u *AuthUsecase) VerifyMFA(ctx context.Context, mfaToken, code, ip string) (*domain.AuthResponse, error) {
+	challenge, err := u.challengeRepo.GetMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrMFAChallengeNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	user, err := u.userRepo.GetByID(ctx, challenge.UserID)
 	if err != nil {
 		return nil, ErrInvalidCredentials
 	}
 
-	// Validate TOTP Code
-	if !security.VerifyMFACode(code, user.MFASecret) {
-		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_FAILED", "", nil)
+	if err := u.checkThrottle(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	attempts, err := u.reserveMFAAttempt(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate TOTP or recovery code
+	ok, err := u.verifySecondFactor(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_FAILED", ip, nil)
+		u.registerFailure(ctx, user.ID, user.Email, ip)
+		u.exhaustMFAChallenge(ctx, mfaToken, user.ID, attempts)
 		return nil, ErrInvalidMFACode
 	}
 
+	// Consume the challenge; if a concurrent request got there first, refuse.
+	deleted, err := u.challengeRepo.DeleteMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, ErrInvalidToken
+	}
+
+	u.clearFailures(ctx, user.Email)
 	return u.generateSession(ctx, user)
 }
 
-// generateSession creates the JWT Access Token and the Opaque Refresh Token.
+// reserveMFAAttempt counts an attempt against the challenge before any code is checked,
+// so parallel requests cannot try more than MFAMaxAttempts codes between them.
+func (u *AuthUsecase) reserveMFAAttempt(ctx context.Context, mfaToken string) (int, error) {
+	attempts, err := u.challengeRepo.ReserveMFAChallengeAttempt(ctx, mfaToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrMFAChallengeNotFound) {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+	if attempts > u.cfg.MFAMaxAttempts {
+		_, _ = u.challengeRepo.DeleteMFAChallenge(ctx, mfaToken)
+		return 0, ErrInvalidToken
+	}
+	return attempts, nil
+}
+
+// exhaustMFAChallenge destroys the challenge once a failed attempt used up the last try.
+func (u *AuthUsecase) exhaustMFAChallenge(ctx context.Context, mfaToken, userID string, attempts int) {
+	if attempts < u.cfg.MFAMaxAttempts {
+		return
+	}
+	_, _ = u.challengeRepo.DeleteMFAChallenge(ctx, mfaToken)
+	_ = u.userRepo.LogSecurityEvent(ctx, userID, "MFA_CHALLENGE_EXHAUSTED", "", nil)
+}
+
+// mfaMethods lists the second factors the user has enrolled.
+func (u *AuthUsecase) mfaMethods(ctx context.Context, user *domain.User) ([]string, error) {
+	var methods []string
+	if user.MFAEnabled {
+		methods = append(methods, "totp")
+	}
+
+	creds, err := u.userRepo.GetWebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(creds) > 0 {
+		methods = append(methods, "webauthn")
+	}
+
+	return methods, nil
+}
+
+// startMFAChallenge stores a single-use challenge for the user and returns it
+// alongside ErrMFARequired.
+func (u *AuthUsecase) startMFAChallenge(ctx context.Context, user *domain.User, methods []string) (*domain.AuthResponse, error) {
+	token, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.challengeRepo.StoreMFAChallenge(ctx, token, user.ID, u.cfg.MFAChallengeTTL); err != nil {
+		return nil, err
+	}
+
+	return &domain.AuthResponse{
+		MFAToken:   token,
+		MFAMethods: methods,
+		ExpiresIn:  int64(u.cfg.MFAChallengeTTL.Seconds()),
+	}, ErrMFARequired
+}
+
+// BeginMFAEnrollment generates a new TOTP secret for the user and stores it as pending.
+// MFA stays disabled until ConfirmMFAEnrollment proves the authenticator was set up.
+func (u *AuthUsecase) BeginMFAEnrollment(ctx context.Context, userID string) (secret, uri string, err error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", "", ErrUserNotFound
+	}
+	if user.MFAEnabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err = security.GenerateMFASecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	user.MFASecret = secret
+	if err := u.userRepo.Update(ctx, user); err != nil {
+		return "", "", err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_ENROLLMENT_STARTED", "", nil)
+
+	return secret, security.GetMFAQRCodeURI(user.Email, secret), nil
+}
+
+// ConfirmMFAEnrollment verifies the first code produced by the authenticator and
+// only then turns MFA on for the account. It returns the initial set of recovery
+// codes, which are shown to the user exactly once.
+func (u *AuthUsecase) ConfirmMFAEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.MFASecret == "" {
+		return nil, ErrMFANotPending
+	}
+
+	if ok, err := u.verifyTOTP(ctx, user, code); err != nil || !ok {
+		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_FAILED", "", nil)
+		return nil, ErrInvalidMFACode
+	}
+
+	// Store the recovery codes before MFA goes live, so a failure here cannot leave the
+	// account with MFA on and no way to recover from a lost authenticator.
+	codes, err := u.issueRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.MFAEnabled = true
+	if err := u.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_ENABLED", "", nil)
+
+	return codes, nil
+}
+
+// DisableMFA turns MFA off after re-authenticating the user with either a current
+// TOTP code, a recovery code or their password. Remaining recovery codes are discarded.
+func (u *AuthUsecase) DisableMFA(ctx context.Context, userID, code, password, ip string) error {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if !user.MFAEnabled {
+		return ErrMFANotEnabled
+	}
+
+	// A stolen session must not be usable to brute-force the code or the password
+	if err := u.checkThrottle(ctx, user.Email, ip); err != nil {
+		return err
+	}
+
+	verified := false
+	if code != "" {
+		verified, err = u.verifySecondFactor(ctx, user, code)
+		if err != nil {
+			return err
+		}
+	} else if password != "" {
+		verified, err = security.ComparePassword(password, user.PasswordHash)
+		if errors.Is(err, security.ErrHashPoolBusy) {
+			return busyError(err)
+		}
+	}
+	if !verified {
+		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_DISABLE_FAILED", ip, nil)
+		u.registerFailure(ctx, user.ID, user.Email, ip)
+		return ErrInvalidCredentials
+	}
+
+	user.MFAEnabled = false
+	user.MFASecret = ""
+	if err := u.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	if err := u.userRepo.ReplaceRecoveryCodes(ctx, user.ID, nil); err != nil {
+		return err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_DISABLED", ip, nil)
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user with a fresh set.
+// A current TOTP code is required so a stolen session alone cannot mint new codes.
+func (u *AuthUsecase) RegenerateRecoveryCodes(ctx context.Context, userID, code, ip string) ([]string, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.MFAEnabled {
+		return nil, ErrMFANotEnabled
+	}
+
+	if err := u.checkThrottle(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	if ok, err := u.verifyTOTP(ctx, user, code); err != nil || !ok {
+		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_FAILED", ip, nil)
+		u.registerFailure(ctx, user.ID, user.Email, ip)
+		return nil, ErrInvalidMFACode
+	}
+
+	return u.issueRecoveryCodes(ctx, user.ID)
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left.
+func (u *AuthUsecase) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return 0, ErrUserNotFound
+	}
+	if !user.MFAEnabled {
+		return 0, ErrMFANotEnabled
+	}
+
+	codes, err := u.userRepo.GetUnusedRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(codes), nil
+}
+
+// issueRecoveryCodes generates a new set of recovery codes, stores their hashes
+// and returns the plaintext codes.
+func (u *AuthUsecase) issueRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes, err := security.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i], err = security.HashPassword(security.NormalizeRecoveryCode(code))
+		if err != nil {
+			return nil, busyError(err)
+		}
+	}
+
+	if err := u.userRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, userID, "MFA_RECOVERY_CODES_GENERATED", "", nil)
+
+	return codes, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+// Recovery codes are spent on success and every use is audited. Both are refused unless
+// TOTP is enrolled and confirmed: a passkey-only account, or one with an enrollment
+// still pending, has no code that may stand in for the second factor.
+func (u *AuthUsecase) verifySecondFactor(ctx context.Context, user *domain.User, code string) (bool, error) {
+	if !user.MFAEnabled || user.MFASecret == "" {
+		return false, nil
+	}
+
+	if security.IsTOTPCode(code) {
+		return u.verifyTOTP(ctx, user, code)
+	}
+
+	codes, err := u.userRepo.GetUnusedRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	normalized := security.NormalizeRecoveryCode(code)
+	for _, rc := range codes {
+		match, err := security.ComparePassword(normalized, rc.CodeHash)
+		if errors.Is(err, security.ErrHashPoolBusy) {
+			return false, busyError(err)
+		}
+		if err != nil || !match {
+			continue
+		}
+
+		spent, err := u.userRepo.MarkRecoveryCodeUsed(ctx, rc.ID)
+		if err != nil || !spent {
+			return false, err
+		}
+
+		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_RECOVERY_CODE_USED", "", map[string]interface{}{
+			"remaining": len(codes) - 1,
+		})
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// verifyTOTP validates a TOTP code and records its time step so the same code
+// cannot be accepted twice, even within its validity window.
+func (u *AuthUsecase) verifyTOTP(ctx context.Context, user *domain.User, code string) (bool, error) {
+	stepTime, ok := security.MatchMFACode(code, user.MFASecret, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	fresh, err := u.userRepo.ConsumeMFAStep(ctx, user.ID, stepTime)
+	if err != nil {
+		return false, err
+	}
+	if !fresh {
+		_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "MFA_REPLAY_REJECTED", "", nil)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Refresh redeems a refresh token and rotates it: the presented token is consumed
+// and a new pair is issued in the same family. Replaying an already rotated token
+// is treated as theft and revokes every token in the family.
+func (u *AuthUsecase) Refresh(ctx context.Context, refreshToken string) (*domain.AuthResponse, error) {
+	return u.rotateRefreshToken(ctx, refreshToken, "")
+}
+
+// rotateRefreshToken implements Refresh for the first party (clientID empty) and for the
+// OAuth refresh grant. A token issued to anyone but clientID has leaked, so it is
+// handled like a replay: the whole family is revoked.
+func (u *AuthUsecase) rotateRefreshToken(ctx context.Context, refreshToken, clientID string) (*domain.AuthResponse, error) {
+	if !security.IsOpaqueToken(refreshToken) {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := u.tokenRepo.ConsumeRefreshToken(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenReused) {
+			_ = u.tokenRepo.RevokeTokenFamily(ctx, session.FamilyID)
+			_ = u.userRepo.LogSecurityEvent(ctx, session.UserID, "REFRESH_TOKEN_REUSE", "", map[string]interface{}{
+				"family_id": session.FamilyID,
+			})
+			return nil, ErrInvalidToken
+		}
+		if errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if session.ClientID != clientID {
+		_ = u.tokenRepo.RevokeTokenFamily(ctx, session.FamilyID)
+		_ = u.userRepo.LogSecurityEvent(ctx, session.UserID, "REFRESH_TOKEN_CLIENT_MISMATCH", "", map[string]interface{}{
+			"family_id":    session.FamilyID,
+			"client_id":    session.ClientID,
+			"presented_by": clientID,
+		})
+		return nil, ErrInvalidToken
+	}
+
+	user, err := u.userRepo.GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return u.issueTokens(ctx, user, session.FamilyID, session.ClientID)
+}
+
+// Logout revokes the presented refresh token. Unknown tokens are ignored so the
+// endpoint is idempotent.
+func (u *AuthUsecase) Logout(ctx context.Context, refreshToken string) error {
+	if !security.IsOpaqueToken(refreshToken) {
+		return nil
+	}
+
+	userID, err := u.tokenRepo.GetUserIDByRefreshToken(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := u.tokenRepo.DeleteRefreshToken(ctx, refreshToken); err != nil {
+		return err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, userID, "LOGOUT", "", nil)
+
+	return nil
+}
+
+// LogoutAll signs the user out everywhere: every refresh token is revoked and
+// access tokens issued until now are rejected by Authenticate.
+func (u *AuthUsecase) LogoutAll(ctx context.Context, userID string) error {
+	if err := u.revokeAllSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, userID, "LOGOUT_ALL", "", nil)
+
+	return nil
+}
+
+// AdminLogoutUser performs a global logout on behalf of an administrator.
+func (u *AuthUsecase) AdminLogoutUser(ctx context.Context, adminID, userID string) error {
+	if _, err := u.userRepo.GetByID(ctx, userID); err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := u.revokeAllSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	_ = u.userRepo.LogSecurityEvent(ctx, userID, "ADMIN_LOGOUT_ALL", "", map[string]interface{}{
+		"admin_id": adminID,
+	})
+
+	return nil
+}
+
+// Authenticate validates an access token and checks it has not been revoked, either on
+// its own (denylisted jti) or by a global logout. Used by token introspection and, through
+// AuthenticateSession, by the JWT middleware on every protected request.
+func (u *AuthUsecase) Authenticate(ctx context.Context, accessToken string) (*security.Claims, error) {
+	claims, err := security.ValidateToken(accessToken, u.cfg.Keys)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ID != "" {
+		denied, err := u.tokenRepo.IsAccessTokenDenied(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if denied {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	validAfter, err := u.tokenRepo.GetTokensValidAfter(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !validAfter.IsZero() && (claims.IssuedAt == nil || !claims.IssuedAt.After(validAfter)) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// AuthenticateSession is Authenticate for the first-party endpoints (account, sessions,
+// MFA, passkeys, administration): tokens issued to an OAuth client are refused with
+// ErrClientToken, whatever the role of their user.
+func (u *AuthUsecase) AuthenticateSession(ctx context.Context, accessToken string) (*security.Claims, error) {
+	claims, err := u.Authenticate(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ClientID != "" {
+		return nil, ErrClientToken
+	}
+	return claims, nil
+}
+
+// revokeAllSessions drops every refresh token and invalidates outstanding access tokens.
+func (u *AuthUsecase) revokeAllSessions(ctx context.Context, userID string) error {
+	if err := u.tokenRepo.RevokeAllUserTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	return u.tokenRepo.SetTokensValidAfter(ctx, userID, sessionCutoff(), accessTokenTTL)
+}
+
+// sessionCutoff is the global logout cut-off for a revocation happening now. JWT iat has
+// second precision, so the cut-off is the end of the previous second: a token issued later
+// in the current second, such as the next login, must stay valid.
+func sessionCutoff() time.Time {
+	return time.Now().Truncate(time.Second).Add(-time.Nanosecond)
+}
+
+// generateSession starts a new refresh token family for a freshly authenticated user.
 func (u *AuthUsecase) generateSession(ctx context.Context, user *domain.User) (*domain.AuthResponse, error) {
+	familyID, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := u.issueTokens(ctx, user, familyID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Log successful login
+	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "LOGIN_SUCCESS", "", nil)
+
+	return resp, nil
+}
+
+// issueTokens creates the JWT Access Token and the Opaque Refresh Token. Both are bound
+// to clientID when they are issued to an OAuth client rather than the first party.
+func (u *AuthUsecase) issueTokens(ctx context.Context, user *domain.User, familyID, clientID string) (*domain.AuthResponse, error) {
+	var scope string
+	if clientID != "" {
+		scope = clientTokenScope
+	}
+
 	// 1. Generate Access Token (JWT) - valid for 15 minutes
-	accessToken, err := security.GenerateAccessToken(user.ID, user.Role, u.jwtSecret, 15*time.Minute)
+	accessToken, err := security.GenerateClientAccessToken(user.ID, user.Role, clientID, scope, u.cfg.Keys, accessTokenTTL)
 	if err != nil {
 		return nil, err
 	}
 
 	// 2. Generate Refresh Token (Opaque)
 	// We use a cryptographically secure random string
-	refreshToken, _ := security.GenerateMFASecret() 
-	
-	// 3. Store Refresh Token in Redis (valid for 24 hours)
-	err = u.tokenRepo.StoreRefreshToken(ctx, user.ID, refreshToken, 24*time.Hour)
+	refreshToken, err := security.GenerateOpaqueToken()
 	if err != nil {
 		return nil, err
 	}
 
-	// 4. Log successful login
-	_ = u.userRepo.LogSecurityEvent(ctx, user.ID, "LOGIN_SUCCESS", "", nil)
+	// 3. Store Refresh Token in Redis (valid for 24 hours)
+	session := domain.RefreshSession{UserID: user.ID, FamilyID: familyID, ClientID: clientID}
+	err = u.tokenRepo.StoreRefreshToken(ctx, session, refreshToken, refreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
 
 	return &domain.AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    900, // 15 minutes in seconds
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+		Scope:        scope,
 	}, nil
-}
\ No newline at end of file
+}
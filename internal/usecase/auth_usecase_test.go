@@ -0,0 +1,277 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+const testPassword = "correct horse battery staple"
+
+// enrollTOTP turns TOTP on for the user and returns the secret, the recovery codes and
+// the code that confirmed the enrollment, whose time step is now consumed.
+func enrollTOTP(t *testing.T, env *testEnv, userID string) (string, []string, string) {
+	t.Helper()
+	ctx := context.Background()
+
+	secret, _, err := env.auth.BeginMFAEnrollment(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmation := totpCode(t, secret, time.Now())
+	codes, err := env.auth.ConfirmMFAEnrollment(ctx, userID, confirmation)
+	if err != nil {
+		t.Fatalf("ConfirmMFAEnrollment: %v", err)
+	}
+	return secret, codes, confirmation
+}
+
+// loginForChallenge signs in with the password and returns the MFA challenge token.
+func loginForChallenge(t *testing.T, env *testEnv, email string) string {
+	t.Helper()
+	resp, err := env.auth.Login(context.Background(), email, testPassword, "198.51.100.7")
+	if !errors.Is(err, ErrMFARequired) {
+		t.Fatalf("Login: err = %v, want ErrMFARequired", err)
+	}
+	return resp.MFAToken
+}
+
+func TestVerifyMFAWithTOTP(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "alice@example.com", testPassword)
+	secret, codes, confirmation := enrollTOTP(t, env, user.ID)
+
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+	stored, _ := env.users.GetByID(ctx, user.ID)
+	if !stored.MFAEnabled {
+		t.Fatal("MFA not enabled after confirmation")
+	}
+
+	// The code used to confirm the enrollment cannot be replayed at login
+	token := loginForChallenge(t, env, user.Email)
+	if _, err := env.auth.VerifyMFA(ctx, token, confirmation, ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed code: err = %v, want ErrInvalidMFACode", err)
+	}
+	env.skipBackoff()
+	if _, err := env.auth.VerifyMFA(ctx, token, "000000", ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("wrong code: err = %v, want ErrInvalidMFACode", err)
+	}
+	env.skipBackoff()
+
+	resp, err := env.auth.VerifyMFA(ctx, token, totpCode(t, secret, nextStep(1)), "")
+	if err != nil {
+		t.Fatalf("fresh code: %v", err)
+	}
+	if resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Fatalf("no session issued: %+v", resp)
+	}
+
+	// The challenge is single-use
+	if _, err := env.auth.VerifyMFA(ctx, token, totpCode(t, secret, nextStep(1)), ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("used challenge: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestVerifyMFAWithRecoveryCode(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "bob@example.com", testPassword)
+	_, codes, _ := enrollTOTP(t, env, user.ID)
+
+	token := loginForChallenge(t, env, user.Email)
+	// Case and separators do not matter
+	if _, err := env.auth.VerifyMFA(ctx, token, " "+codes[0][:5]+codes[0][6:]+" ", ""); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if !env.users.hasEvent("MFA_RECOVERY_CODE_USED") {
+		t.Fatal("recovery code use was not audited")
+	}
+
+	// A spent code is refused
+	token = loginForChallenge(t, env, user.Email)
+	if _, err := env.auth.VerifyMFA(ctx, token, codes[0], ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("spent code: err = %v, want ErrInvalidMFACode", err)
+	}
+	if n, _ := env.auth.CountRecoveryCodes(ctx, user.ID); n != recoveryCodeCount-1 {
+		t.Fatalf("%d codes left, want %d", n, recoveryCodeCount-1)
+	}
+}
+
+func TestVerifyMFARequiresConfirmedTOTP(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "carol@example.com", testPassword)
+
+	// A passkey-only account gets a challenge, but no code may answer it
+	if err := env.users.CreateWebAuthnCredential(ctx, &domain.WebAuthnCredential{UserID: user.ID, Name: "key"}); err != nil {
+		t.Fatal(err)
+	}
+	token := loginForChallenge(t, env, user.Email)
+
+	for name, code := range map[string]string{
+		"empty secret": totpCode(t, "", time.Now()),
+		"zero code":    "000000",
+	} {
+		if _, err := env.auth.VerifyMFA(ctx, token, code, ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("%s: err = %v, want ErrInvalidMFACode", name, err)
+		}
+		env.skipBackoff()
+	}
+
+	// Nor may the secret of an enrollment that was never confirmed
+	secret, _, err := env.auth.BeginMFAEnrollment(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.auth.VerifyMFA(ctx, token, totpCode(t, secret, time.Now()), ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("pending secret: err = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestVerifyMFAChallengeAttemptLimit(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "dave@example.com", testPassword)
+	secret, _, _ := enrollTOTP(t, env, user.ID)
+
+	// Lockout would otherwise kick in before the challenge is used up
+	env.auth.cfg.MaxAccountFailures = 100
+
+	token := loginForChallenge(t, env, user.Email)
+	for i := 0; i < env.auth.cfg.MFAMaxAttempts; i++ {
+		if _, err := env.auth.VerifyMFA(ctx, token, "000000", ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidMFACode", i+1, err)
+		}
+		env.skipBackoff()
+	}
+
+	if _, err := env.auth.VerifyMFA(ctx, token, totpCode(t, secret, nextStep(1)), ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("exhausted challenge: err = %v, want ErrInvalidToken", err)
+	}
+	if !env.users.hasEvent("MFA_CHALLENGE_EXHAUSTED") {
+		t.Fatal("exhausted challenge was not audited")
+	}
+}
+
+func TestMFAManagementIsThrottled(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "erin@example.com", testPassword)
+	secret, _, _ := enrollTOTP(t, env, user.ID)
+	const ip = "203.0.113.9"
+
+	// Wrong codes and passwords count towards the same lockout as failed logins
+	for i := 1; i < env.auth.cfg.MaxAccountFailures; i++ {
+		var err error
+		if i%2 == 0 {
+			_, err = env.auth.RegenerateRecoveryCodes(ctx, user.ID, "000000", ip)
+		} else {
+			err = env.auth.DisableMFA(ctx, user.ID, "", "wrong password", ip)
+		}
+		if !errors.Is(err, ErrInvalidMFACode) && !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want a verification error", i, err)
+		}
+
+		// Retrying inside the progressive delay is refused without checking anything
+		if i > 1 {
+			var throttle *ThrottleError
+			if err := env.auth.DisableMFA(ctx, user.ID, "", testPassword, ip); !errors.As(err, &throttle) || !errors.Is(err, ErrTooManyAttempts) {
+				t.Fatalf("attempt %d: retry err = %v, want ErrTooManyAttempts", i, err)
+			}
+		}
+		env.skipBackoff()
+	}
+
+	if err := env.auth.DisableMFA(ctx, user.ID, "000000", "", ip); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("last attempt: err = %v, want ErrInvalidCredentials", err)
+	}
+	env.skipBackoff()
+	if _, err := env.auth.RegenerateRecoveryCodes(ctx, user.ID, totpCode(t, secret, nextStep(1)), ip); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("locked account: err = %v, want ErrAccountLocked", err)
+	}
+
+	for _, e := range env.users.events {
+		if e.Type == "MFA_DISABLE_FAILED" && e.IP != ip {
+			t.Fatalf("MFA_DISABLE_FAILED logged with IP %q, want %q", e.IP, ip)
+		}
+	}
+}
+
+func TestRegisterFlagsBreachedPassword(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	sum := sha1.Sum([]byte(testPassword))
+	if err := os.WriteFile(path, []byte(strings.ToUpper(hex.EncodeToString(sum[:]))+":42\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	breaches, err := security.OpenBreachCorpus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer breaches.Close()
+
+	defer func(p security.PasswordPolicy) { security.DefaultPasswordPolicy = p }(security.DefaultPasswordPolicy)
+	security.DefaultPasswordPolicy.Breaches = breaches
+	security.DefaultPasswordPolicy.RejectBreached = false
+
+	if err := env.auth.Register(ctx, "Erin <Erin@Example.com>", testPassword, "203.0.113.9"); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := env.users.GetByEmail(ctx, "erin@example.com")
+	if err != nil {
+		t.Fatalf("account not stored under the parsed address: %v", err)
+	}
+
+	var flagged bool
+	for _, e := range env.users.events {
+		if e.Type == "BREACHED_PASSWORD" {
+			flagged = e.UserID == user.ID && e.IP == "203.0.113.9" && e.Metadata["occurrences"] == 42
+		}
+	}
+	if !flagged {
+		t.Fatalf("no BREACHED_PASSWORD event with the client IP: %+v", env.users.events)
+	}
+}
+
+func TestLoginRightAfterLogoutAll(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.createUser(t, "frank@example.com", testPassword)
+
+	old, err := env.auth.Login(ctx, user.Email, testPassword, "198.51.100.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// iat has second precision: the cut-off must not reach into the current second
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+	if err := env.auth.LogoutAll(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := env.auth.Login(ctx, user.Email, testPassword, "198.51.100.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := env.auth.Authenticate(ctx, fresh.AccessToken); err != nil {
+		t.Fatalf("token issued in the second of the logout: %v", err)
+	}
+	if _, err := env.auth.Authenticate(ctx, old.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token issued before the logout: err = %v, want ErrInvalidToken", err)
+	}
+}
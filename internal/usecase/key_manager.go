@@ -0,0 +1,278 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+// keyRetentionSkew is added to the access token lifetime before a retired key is deleted,
+// to absorb clock skew between replicas and resource servers.
+const keyRetentionSkew = 5 * time.Minute
+
+// Bounds of the backoff used by Start while another replica creates the first key.
+const (
+	startBackoffMin = 100 * time.Millisecond
+	startBackoffMax = 5 * time.Second
+)
+
+// ErrNoActiveSigningKey is returned by Sync when the database holds no active key.
+var ErrNoActiveSigningKey = errors.New("no active signing key")
+
+// KeyManagerConfig holds the rotation schedule of the JWT signing keys.
+// Zero values fall back to sensible defaults in NewKeyManager.
+type KeyManagerConfig struct {
+	Algorithm        string        // RS256, ES256, ES384, ES512 or EdDSA
+	RotationInterval time.Duration // how long a key signs before the next one takes over
+	// PublishLead is how long a new key is published in the JWKS before it signs.
+	// It must exceed the JWKS cache lifetime so resource servers know the key in time.
+	PublishLead  time.Duration
+	SyncInterval time.Duration // how often each replica reloads the key set
+	// EncryptionKey is the 32-byte key encryption key protecting stored private keys.
+	EncryptionKey []byte
+}
+
+// KeyManager rotates the JWT signing keys stored in the database and keeps the local
+// keyring in sync with them. Keys move pending -> active -> retired -> deleted:
+//
+//   - a pending key is published (JWKS, verification) PublishLead before it signs;
+//   - the active key signs every new token, and there is exactly one across replicas;
+//   - a retired key is still accepted until every token it signed has expired;
+//   - a deleted key has its material wiped.
+//
+// Every replica runs the manager; a database lock ensures only one changes the key set
+// at a time, and the others pick the change up on their next sync.
+type KeyManager struct {
+	repo domain.SigningKeyRepository
+	keys *security.Keyring
+	cfg  KeyManagerConfig
+
+	mu     sync.Mutex
+	opened map[string]*security.SigningKey // decrypted keys by kid
+}
+
+func NewKeyManager(repo domain.SigningKeyRepository, keys *security.Keyring, cfg KeyManagerConfig) *KeyManager {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = "ES256"
+	}
+	if cfg.RotationInterval <= 0 {
+		cfg.RotationInterval = 30 * 24 * time.Hour
+	}
+	if cfg.PublishLead <= 0 {
+		cfg.PublishLead = time.Hour
+	}
+	if cfg.PublishLead >= cfg.RotationInterval {
+		cfg.PublishLead = cfg.RotationInterval / 2
+	}
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = time.Minute
+	}
+
+	return &KeyManager{
+		repo:   repo,
+		keys:   keys,
+		cfg:    cfg,
+		opened: make(map[string]*security.SigningKey),
+	}
+}
+
+// retention is how long a retired key stays verifiable: the longest token lifetime plus skew.
+func (m *KeyManager) retention() time.Duration {
+	return accessTokenTTL + keyRetentionSkew
+}
+
+// Start performs the first Refresh. On a fresh database every replica races to create
+// the first key; those that lose the lock find no active key until the winner commits it,
+// so they retry with backoff until a key shows up or ctx ends. A retry also takes the
+// lock itself if the winner died before creating the key.
+func (m *KeyManager) Start(ctx context.Context) error {
+	backoff := startBackoffMin
+	for {
+		err := m.Refresh(ctx)
+		if !errors.Is(err, ErrNoActiveSigningKey) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, startBackoffMax)
+	}
+}
+
+// Refresh advances the rotation schedule if this replica gets the lock, then reloads the
+// keyring. Called by Start and then by Run.
+func (m *KeyManager) Refresh(ctx context.Context) error {
+	if err := m.Rotate(ctx); err != nil {
+		return err
+	}
+	return m.Sync(ctx)
+}
+
+// Run refreshes the keys every SyncInterval until ctx is cancelled. Errors are logged;
+// the keyring keeps its last good state.
+func (m *KeyManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Refresh(ctx); err != nil {
+				log.Printf("signing key refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+// Rotate moves keys through their lifecycle. It does nothing if another replica holds
+// the rotation lock.
+func (m *KeyManager) Rotate(ctx context.Context) error {
+	unlock, ok, err := m.repo.TryLockSigningKeys(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	defer unlock()
+
+	stored, err := m.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var active, pending *domain.SigningKey
+	for _, k := range stored {
+		switch k.State {
+		case domain.SigningKeyActive:
+			active = k
+		case domain.SigningKeyPending:
+			pending = k
+		case domain.SigningKeyRetired:
+			if k.RetiredAt != nil && now.Sub(*k.RetiredAt) >= m.retention() {
+				if err := m.repo.DeleteSigningKey(ctx, k.ID); err != nil {
+					return err
+				}
+				log.Printf("signing key %s deleted", k.ID)
+			}
+		}
+	}
+
+	// First start: nothing has been issued yet, so the key can sign right away
+	if active == nil && pending == nil {
+		_, err := m.createKey(ctx, domain.SigningKeyActive)
+		return err
+	}
+	if active == nil {
+		return m.activate(ctx, pending)
+	}
+
+	since := active.CreatedAt
+	if active.ActivatedAt != nil {
+		since = *active.ActivatedAt
+	}
+	age := now.Sub(since)
+
+	if pending == nil && age >= m.cfg.RotationInterval-m.cfg.PublishLead {
+		_, err := m.createKey(ctx, domain.SigningKeyPending)
+		return err
+	}
+	if pending != nil && age >= m.cfg.RotationInterval && now.Sub(pending.CreatedAt) >= m.cfg.PublishLead {
+		return m.activate(ctx, pending)
+	}
+
+	return nil
+}
+
+// Sync loads the stored keys into the keyring: the active key signs, pending keys and
+// retired keys within their retention are accepted.
+func (m *KeyManager) Sync(ctx context.Context) error {
+	stored, err := m.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var current *security.SigningKey
+	var verify []*security.SigningKey
+	live := make(map[string]*security.SigningKey)
+
+	for _, k := range stored {
+		if k.State == domain.SigningKeyRetired && k.RetiredAt != nil && now.Sub(*k.RetiredAt) >= m.retention() {
+			continue
+		}
+
+		key, err := m.open(k)
+		if err != nil {
+			return err
+		}
+		live[k.ID] = key
+
+		if k.State == domain.SigningKeyActive {
+			current = key
+		} else {
+			verify = append(verify, key)
+		}
+	}
+	if current == nil {
+		return ErrNoActiveSigningKey
+	}
+
+	m.opened = live
+	return m.keys.Reset(current, verify...)
+}
+
+// open decrypts a stored key, reusing the result of earlier syncs. Must hold m.mu.
+func (m *KeyManager) open(k *domain.SigningKey) (*security.SigningKey, error) {
+	if key, ok := m.opened[k.ID]; ok {
+		return key, nil
+	}
+	return security.OpenSigningKey(k.ID, k.EncryptedKey, m.cfg.EncryptionKey)
+}
+
+func (m *KeyManager) createKey(ctx context.Context, state string) (*domain.SigningKey, error) {
+	key, err := security.GenerateSigningKey(m.cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := security.SealSigningKey(key, m.cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &domain.SigningKey{
+		ID:           key.ID,
+		Algorithm:    key.Method.Alg(),
+		State:        state,
+		EncryptedKey: sealed,
+		CreatedAt:    time.Now(),
+	}
+	if state == domain.SigningKeyActive {
+		record.ActivatedAt = &record.CreatedAt
+	}
+	if err := m.repo.CreateSigningKey(ctx, record); err != nil {
+		return nil, err
+	}
+
+	log.Printf("signing key %s (%s) created as %s", record.ID, record.Algorithm, state)
+	return record, nil
+}
+
+func (m *KeyManager) activate(ctx context.Context, pending *domain.SigningKey) error {
+	if err := m.repo.ActivateSigningKey(ctx, pending.ID); err != nil {
+		return err
+	}
+	log.Printf("signing key %s activated", pending.ID)
+	return nil
+}
@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+// memSigningKeyRepo is an in-memory domain.SigningKeyRepository with a lock that tests
+// can hold as if another replica had it.
+type memSigningKeyRepo struct {
+	mu     sync.Mutex
+	keys   []*domain.SigningKey
+	locked bool
+}
+
+func (r *memSigningKeyRepo) ListSigningKeys(ctx context.Context) ([]*domain.SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]*domain.SigningKey, len(r.keys))
+	for i, k := range r.keys {
+		copied := *k
+		keys[i] = &copied
+	}
+	return keys, nil
+}
+
+func (r *memSigningKeyRepo) CreateSigningKey(ctx context.Context, key *domain.SigningKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *key
+	r.keys = append(r.keys, &copied)
+	return nil
+}
+
+func (r *memSigningKeyRepo) ActivateSigningKey(ctx context.Context, id string) error {
+	return errors.New("not implemented")
+}
+
+func (r *memSigningKeyRepo) DeleteSigningKey(ctx context.Context, id string) error {
+	return errors.New("not implemented")
+}
+
+func (r *memSigningKeyRepo) TryLockSigningKeys(ctx context.Context) (func(), bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked {
+		return nil, false, nil
+	}
+	r.locked = true
+	return r.unlock, true, nil
+}
+
+func (r *memSigningKeyRepo) unlock() {
+	r.mu.Lock()
+	r.locked = false
+	r.mu.Unlock()
+}
+
+func newTestKeyManager(repo domain.SigningKeyRepository) (*KeyManager, *security.Keyring) {
+	keys := security.NewHMACKeyring("placeholder-until-the-first-sync")
+	return NewKeyManager(repo, keys, KeyManagerConfig{EncryptionKey: bytes.Repeat([]byte{7}, 32)}), keys
+}
+
+func TestKeyManagerStartCreatesFirstKey(t *testing.T) {
+	repo := &memSigningKeyRepo{}
+	manager, keys := newTestKeyManager(repo)
+
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.keys) != 1 || repo.keys[0].State != domain.SigningKeyActive {
+		t.Fatalf("stored keys = %+v, want one active key", repo.keys)
+	}
+	if keys.Current().ID != repo.keys[0].ID {
+		t.Fatalf("keyring signs with %s, want %s", keys.Current().ID, repo.keys[0].ID)
+	}
+}
+
+func TestKeyManagerStartWaitsForAnotherReplica(t *testing.T) {
+	repo := &memSigningKeyRepo{locked: true}
+	manager, keys := newTestKeyManager(repo)
+
+	// Another replica holds the lock and commits the first key a little later
+	winner, _ := newTestKeyManager(repo)
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		if _, err := winner.createKey(context.Background(), domain.SigningKeyActive); err != nil {
+			t.Error(err)
+		}
+		repo.unlock()
+	}()
+
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if len(repo.keys) != 1 {
+		t.Fatalf("%d keys stored, want the winner's only", len(repo.keys))
+	}
+	if keys.Current().ID != repo.keys[0].ID {
+		t.Fatalf("keyring signs with %s, want %s", keys.Current().ID, repo.keys[0].ID)
+	}
+}
+
+func TestKeyManagerStartGivesUpWithContext(t *testing.T) {
+	repo := &memSigningKeyRepo{locked: true}
+	manager, _ := newTestKeyManager(repo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := manager.Start(ctx); !errors.Is(err, ErrNoActiveSigningKey) {
+		t.Fatalf("err = %v, want ErrNoActiveSigningKey", err)
+	}
+}
@@ -0,0 +1,227 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+var (
+	ErrInvalidGrant                = errors.New("invalid_grant")
+	ErrInvalidAuthorizationRequest = errors.New("authorization request expired or invalid")
+)
+
+const (
+	// authorizationRequestTTL bounds how long the user may take to sign in.
+	authorizationRequestTTL = 10 * time.Minute
+	// authorizationCodeTTL is kept short: the client redeems the code right after the redirect.
+	authorizationCodeTTL = time.Minute
+)
+
+// AuthorizeParams are the query parameters of an authorization request.
+type AuthorizeParams struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// AuthorizationError is an error of the authorization endpoint that is reported to the
+// client by redirecting the user back to it (RFC 6749 section 4.1.2.1).
+type AuthorizationError struct {
+	Code        string
+	Description string
+	RedirectURI string
+	State       string
+}
+
+func (e *AuthorizationError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// RedirectURL is where the user agent is sent to deliver the error to the client.
+func (e *AuthorizationError) RedirectURL() string {
+	params := url.Values{"error": {e.Code}}
+	if e.Description != "" {
+		params.Set("error_description", e.Description)
+	}
+	if e.State != "" {
+		params.Set("state", e.State)
+	}
+	return withQuery(e.RedirectURI, params)
+}
+
+// StartAuthorization validates an authorization request and keeps it until the user has
+// signed in, returning the handle under which the login page approves it. An unknown
+// client or unregistered redirect URI yields ErrInvalidClient or ErrInvalidRedirectURI,
+// which must not be redirected; any later problem is an *AuthorizationError.
+func (o *OAuthUsecase) StartAuthorization(ctx context.Context, p AuthorizeParams) (string, error) {
+	client, err := o.clients.GetOAuthClient(ctx, p.ClientID)
+	if err != nil {
+		if errors.Is(err, domain.ErrOAuthClientNotFound) {
+			return "", ErrInvalidClient
+		}
+		return "", err
+	}
+
+	// Exact string match only: no prefix, wildcard or normalisation
+	registered := false
+	for _, uri := range client.RedirectURIs {
+		if uri == p.RedirectURI {
+			registered = true
+			break
+		}
+	}
+	if !registered {
+		return "", ErrInvalidRedirectURI
+	}
+
+	fail := func(code, description string) (string, error) {
+		return "", &AuthorizationError{Code: code, Description: description, RedirectURI: p.RedirectURI, State: p.State}
+	}
+	switch {
+	case p.ResponseType != "code":
+		return fail("unsupported_response_type", "only response_type=code is supported")
+	case p.CodeChallenge == "":
+		return fail("invalid_request", "code_challenge is required")
+	case p.CodeChallengeMethod != "S256":
+		return fail("invalid_request", "code_challenge_method must be S256")
+	case !security.ValidPKCEChallenge(p.CodeChallenge):
+		return fail("invalid_request", "malformed code_challenge")
+	}
+
+	requestID, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	req := domain.AuthorizationRequest{
+		ClientID:      client.ID,
+		RedirectURI:   p.RedirectURI,
+		State:         p.State,
+		CodeChallenge: p.CodeChallenge,
+	}
+	if err := o.codes.StoreAuthorizationRequest(ctx, security.HashOpaqueToken(requestID), req, authorizationRequestTTL); err != nil {
+		return "", err
+	}
+
+	return requestID, nil
+}
+
+// CompleteAuthorization finishes a pending request once the login page has signed the
+// user in through the regular login, MFA or passkey endpoints. It returns the URL the
+// user agent must be sent to: the client's redirect URI carrying either a single-use
+// code and the state, or access_denied if the user declined.
+func (o *OAuthUsecase) CompleteAuthorization(ctx context.Context, requestID, userID, ip string, approve bool) (string, error) {
+	req, err := o.codes.ConsumeAuthorizationRequest(ctx, security.HashOpaqueToken(requestID))
+	if err != nil {
+		if errors.Is(err, domain.ErrAuthorizationRequestNotFound) {
+			return "", ErrInvalidAuthorizationRequest
+		}
+		return "", err
+	}
+
+	if !approve {
+		denied := &AuthorizationError{
+			Code:        "access_denied",
+			Description: "the user denied the request",
+			RedirectURI: req.RedirectURI,
+			State:       req.State,
+		}
+		return denied.RedirectURL(), nil
+	}
+
+	code, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	grant := domain.AuthorizationCode{
+		ClientID:      req.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+	}
+	if err := o.codes.StoreAuthorizationCode(ctx, security.HashOpaqueToken(code), grant, authorizationCodeTTL); err != nil {
+		return "", err
+	}
+
+	_ = o.auth.userRepo.LogSecurityEvent(ctx, userID, "OAUTH_CODE_ISSUED", ip, map[string]interface{}{
+		"client_id": req.ClientID,
+	})
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return withQuery(req.RedirectURI, params), nil
+}
+
+// ExchangeCode redeems an authorization code at the token endpoint. The code is consumed
+// before anything else is checked, so a failed attempt also burns it; it must have been
+// issued to this client for this redirect URI, and the PKCE verifier must match.
+func (o *OAuthUsecase) ExchangeCode(ctx context.Context, client *domain.OAuthClient, code, redirectURI, verifier, ip string) (*domain.AuthResponse, error) {
+	grant, err := o.codes.ConsumeAuthorizationCode(ctx, security.HashOpaqueToken(code))
+	if err != nil {
+		if errors.Is(err, domain.ErrAuthorizationCodeNotFound) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	if grant.ClientID != client.ID || grant.RedirectURI != redirectURI || !security.VerifyPKCE(verifier, grant.CodeChallenge) {
+		return nil, ErrInvalidGrant
+	}
+
+	user, err := o.auth.userRepo.GetByID(ctx, grant.UserID)
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	familyID, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.auth.issueTokens(ctx, user, familyID, client.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = o.auth.userRepo.LogSecurityEvent(ctx, user.ID, "OAUTH_TOKEN_ISSUED", ip, map[string]interface{}{
+		"client_id": client.ID,
+	})
+
+	return resp, nil
+}
+
+// RefreshGrant rotates a refresh token at the token endpoint, with the same reuse
+// detection as the first-party refresh endpoint. Only the client the token was issued
+// to may redeem it; first-party refresh tokens are refused.
+func (o *OAuthUsecase) RefreshGrant(ctx context.Context, client *domain.OAuthClient, refreshToken string) (*domain.AuthResponse, error) {
+	resp, err := o.auth.rotateRefreshToken(ctx, refreshToken, client.ID)
+	if err == ErrInvalidToken {
+		return nil, ErrInvalidGrant
+	}
+	return resp, err
+}
+
+// withQuery adds params to a URI, keeping the query it already has.
+func withQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
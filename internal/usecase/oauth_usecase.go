@@ -0,0 +1,320 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+var (
+	ErrInvalidClient      = errors.New("invalid_client")
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+)
+
+// clientTokenScope is the scope of every token issued to an OAuth client: the identity
+// and role of the user, for the client's resource servers. The first-party endpoints of
+// this service refuse such tokens (see AuthenticateSession).
+const clientTokenScope = "profile"
+
+// OAuthUsecase implements the OAuth 2.0 endpoints used by registered clients:
+// client registration, the authorization code grant with PKCE (RFC 6749, RFC 7636),
+// token introspection (RFC 7662) and revocation (RFC 7009).
+type OAuthUsecase struct {
+	auth    *AuthUsecase
+	clients domain.OAuthClientRepository
+	codes   domain.AuthorizationCodeRepository
+}
+
+func NewOAuthUsecase(a *AuthUsecase, c domain.OAuthClientRepository, codes domain.AuthorizationCodeRepository) *OAuthUsecase {
+	return &OAuthUsecase{
+		auth:    a,
+		clients: c,
+		codes:   codes,
+	}
+}
+
+// RegisterClient creates an OAuth client. Confidential clients get a secret, returned
+// only here; public clients (browser or mobile apps) get none. Redirect URIs are checked
+// by validRedirectURI.
+func (o *OAuthUsecase) RegisterClient(ctx context.Context, adminID, name string, redirectURIs []string, public bool) (*domain.OAuthClient, string, error) {
+	for _, raw := range redirectURIs {
+		if !validRedirectURI(raw) {
+			return nil, "", ErrInvalidRedirectURI
+		}
+	}
+
+	id, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	client := &domain.OAuthClient{
+		ID:           id[:22], // 128 bits
+		Name:         strings.TrimSpace(name),
+		RedirectURIs: redirectURIs,
+	}
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+
+	var secret string
+	if !public {
+		if secret, err = security.GenerateOpaqueToken(); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = security.HashOpaqueToken(secret)
+	}
+
+	if err := o.clients.CreateOAuthClient(ctx, client); err != nil {
+		return nil, "", err
+	}
+
+	_ = o.auth.userRepo.LogSecurityEvent(ctx, adminID, "OAUTH_CLIENT_CREATED", "", map[string]interface{}{
+		"client_id":    client.ID,
+		"confidential": !public,
+	})
+
+	return client, secret, nil
+}
+
+// validRedirectURI accepts absolute URIs without fragment or credentials that use https,
+// http on a loopback address (native apps, RFC 8252 section 7.3), or a private-use scheme
+// in reverse domain form such as com.example.app (RFC 8252 section 7.1). Anything else,
+// javascript:, data: or file: included, could run or read something in the user agent.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || u.User != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	default:
+		return strings.Contains(u.Scheme, ".")
+	}
+}
+
+// AuthenticateClient checks the credentials of a confidential client.
+func (o *OAuthUsecase) AuthenticateClient(ctx context.Context, clientID, secret string) (*domain.OAuthClient, error) {
+	if clientID == "" || secret == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := o.clients.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, domain.ErrOAuthClientNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	hash := security.HashOpaqueToken(secret)
+	if !client.Confidential() || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// IdentifyClient authenticates a confidential client, or accepts a public client by its
+// ID alone, since it has no secret to present.
+func (o *OAuthUsecase) IdentifyClient(ctx context.Context, clientID, secret string) (*domain.OAuthClient, error) {
+	if secret != "" {
+		return o.AuthenticateClient(ctx, clientID, secret)
+	}
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := o.clients.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, domain.ErrOAuthClientNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+	if client.Confidential() {
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// Introspect describes an access token (JWT) or refresh token (opaque) to an authenticated
+// client, with the client and scope it is bound to (none for first-party tokens). Tokens
+// that are invalid, expired or revoked are reported as inactive; hint ("access_token" or
+// "refresh_token") only decides which kind is tried first.
+func (o *OAuthUsecase) Introspect(ctx context.Context, client *domain.OAuthClient, token, hint string) (*domain.TokenIntrospection, error) {
+	lookups := []func(context.Context, string) (*domain.TokenIntrospection, error){
+		o.introspectAccessToken,
+		o.introspectRefreshToken,
+	}
+	if hint == "refresh_token" {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		result, err := lookup(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if result.Active {
+			return result, nil
+		}
+	}
+
+	return &domain.TokenIntrospection{Active: false}, nil
+}
+
+// introspectAccessToken validates a JWT, including the global logout cut-off.
+func (o *OAuthUsecase) introspectAccessToken(ctx context.Context, token string) (*domain.TokenIntrospection, error) {
+	claims, err := o.auth.Authenticate(ctx, token)
+	if err != nil {
+		if err == ErrInvalidToken {
+			return &domain.TokenIntrospection{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	result := &domain.TokenIntrospection{
+		Active:    true,
+		TokenType: "Bearer",
+		Subject:   claims.UserID,
+		Issuer:    claims.Issuer,
+		Role:      claims.Role,
+		TokenID:   claims.ID,
+		ClientID:  claims.ClientID,
+		Scope:     claims.Scope,
+	}
+	if claims.ExpiresAt != nil {
+		result.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		result.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		result.NotBefore = claims.NotBefore.Unix()
+	}
+	return result, nil
+}
+
+// introspectRefreshToken looks an opaque refresh token up without consuming it.
+func (o *OAuthUsecase) introspectRefreshToken(ctx context.Context, token string) (*domain.TokenIntrospection, error) {
+	if !security.IsOpaqueToken(token) {
+		return &domain.TokenIntrospection{Active: false}, nil
+	}
+
+	session, err := o.auth.tokenRepo.GetRefreshSession(ctx, token)
+	if err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			return &domain.TokenIntrospection{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	result := &domain.TokenIntrospection{
+		Active:    true,
+		TokenType: "refresh_token",
+		Subject:   session.UserID,
+		Issuer:    security.TokenIssuer,
+		ExpiresAt: session.ExpiresAt.Unix(),
+		ClientID:  session.ClientID,
+	}
+	if session.ClientID != "" {
+		result.Scope = clientTokenScope
+	}
+	return result, nil
+}
+
+// Revoke invalidates a refresh token or an access token (RFC 7009). Refresh tokens are
+// deleted; access tokens are denylisted by jti until they expire. Unknown, invalid or
+// already expired tokens are ignored, as the RFC requires the same response for them;
+// so are tokens issued to another client or to a first-party session (section 2.1).
+func (o *OAuthUsecase) Revoke(ctx context.Context, client *domain.OAuthClient, token, hint string) error {
+	revokers := []func(context.Context, *domain.OAuthClient, string) (bool, error){
+		o.revokeAccessToken,
+		o.revokeRefreshToken,
+	}
+	if hint == "refresh_token" {
+		revokers[0], revokers[1] = revokers[1], revokers[0]
+	}
+
+	for _, revoke := range revokers {
+		done, err := revoke(ctx, client, token)
+		if err != nil || done {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revokeAccessToken denylists a valid access token of the client for the rest of its lifetime.
+func (o *OAuthUsecase) revokeAccessToken(ctx context.Context, client *domain.OAuthClient, token string) (bool, error) {
+	claims, err := security.ValidateToken(token, o.auth.cfg.Keys)
+	if err != nil {
+		return false, nil
+	}
+	if claims.ClientID != client.ID {
+		return true, nil
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		// Issued before token IDs existed; it expires within accessTokenTTL anyway
+		return true, nil
+	}
+
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return true, nil
+	}
+	if err := o.auth.tokenRepo.DenyAccessToken(ctx, claims.ID, ttl); err != nil {
+		return false, err
+	}
+
+	_ = o.auth.userRepo.LogSecurityEvent(ctx, claims.UserID, "ACCESS_TOKEN_REVOKED", "", map[string]interface{}{
+		"client_id": client.ID,
+		"jti":       claims.ID,
+	})
+	return true, nil
+}
+
+// revokeRefreshToken deletes a live refresh token of the client.
+func (o *OAuthUsecase) revokeRefreshToken(ctx context.Context, client *domain.OAuthClient, token string) (bool, error) {
+	if !security.IsOpaqueToken(token) {
+		return false, nil
+	}
+
+	session, err := o.auth.tokenRepo.GetRefreshSession(ctx, token)
+	if err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if session.ClientID != client.ID {
+		return true, nil
+	}
+
+	if err := o.auth.tokenRepo.DeleteRefreshToken(ctx, token); err != nil {
+		return false, err
+	}
+
+	_ = o.auth.userRepo.LogSecurityEvent(ctx, session.UserID, "REFRESH_TOKEN_REVOKED", "", map[string]interface{}{
+		"client_id": client.ID,
+	})
+	return true, nil
+}
@@ -0,0 +1,349 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pquerna/otp/totp"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/FilipeAphrody/sentinel-auth/internal/repository"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+func TestMain(m *testing.M) {
+	// Production Argon2id costs make every hash take tens of milliseconds
+	security.DefaultParams.Memory = 64
+	security.DefaultParams.Iterations = 1
+	security.DefaultParams.Parallelism = 1
+	os.Exit(m.Run())
+}
+
+// memUserRepo is an in-memory domain.UserRepository. Every user is returned as a copy,
+// so usecases hold stale snapshots exactly as they would with a database.
+type memUserRepo struct {
+	mu            sync.Mutex
+	users         map[string]*domain.User
+	mfaLastStep   map[string]int64
+	history       map[string][]string
+	recoveryCodes map[string][]memRecoveryCode
+	credentials   map[string][]domain.WebAuthnCredential
+	events        []memEvent
+	nextID        int
+}
+
+type memRecoveryCode struct {
+	domain.RecoveryCode
+	used bool
+}
+
+type memEvent struct {
+	UserID, Type, IP string
+	Metadata         map[string]interface{}
+}
+
+func newMemUserRepo() *memUserRepo {
+	return &memUserRepo{
+		users:         map[string]*domain.User{},
+		mfaLastStep:   map[string]int64{},
+		history:       map[string][]string{},
+		recoveryCodes: map[string][]memRecoveryCode{},
+		credentials:   map[string][]domain.WebAuthnCredential{},
+	}
+}
+
+func (r *memUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Email == email {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (r *memUserRepo) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (r *memUserRepo) Create(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Email == user.Email {
+			return domain.ErrUserAlreadyExists
+		}
+	}
+	r.nextID++
+	user.ID = fmt.Sprintf("user-%d", r.nextID)
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func (r *memUserRepo) Update(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[user.ID]; !ok {
+		return errors.New("user not found")
+	}
+	// Like the SQL UPDATE, every column but password_hash is written
+	user.UpdatedAt = time.Now()
+	copied := *user
+	copied.PasswordHash = r.users[user.ID].PasswordHash
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func (r *memUserRepo) UpdatePasswordHash(ctx context.Context, userID, oldHash, newHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok || u.PasswordHash != oldHash {
+		return false, nil
+	}
+	u.PasswordHash = newHash
+	u.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *memUserRepo) ConsumeMFAStep(ctx context.Context, userID string, stepTime int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mfaLastStep[userID] >= stepTime {
+		return false, nil
+	}
+	r.mfaLastStep[userID] = stepTime
+	return true, nil
+}
+
+func (r *memUserRepo) CountPasswordHashPrefixes(ctx context.Context) (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
+func (r *memUserRepo) GetPasswordHistory(ctx context.Context, userID string, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hashes := r.history[userID]
+	if len(hashes) > limit {
+		hashes = hashes[:limit]
+	}
+	return append([]string(nil), hashes...), nil
+}
+
+func (r *memUserRepo) AddPasswordHistory(ctx context.Context, userID, passwordHash string, keep int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hashes := append([]string{passwordHash}, r.history[userID]...)
+	if len(hashes) > keep {
+		hashes = hashes[:keep]
+	}
+	r.history[userID] = hashes
+	return nil
+}
+
+func (r *memUserRepo) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	codes := make([]memRecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		r.nextID++
+		codes[i] = memRecoveryCode{RecoveryCode: domain.RecoveryCode{
+			ID:       fmt.Sprintf("code-%d", r.nextID),
+			UserID:   userID,
+			CodeHash: hash,
+		}}
+	}
+	r.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (r *memUserRepo) GetUnusedRecoveryCodes(ctx context.Context, userID string) ([]domain.RecoveryCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var codes []domain.RecoveryCode
+	for _, c := range r.recoveryCodes[userID] {
+		if !c.used {
+			codes = append(codes, c.RecoveryCode)
+		}
+	}
+	return codes, nil
+}
+
+func (r *memUserRepo) MarkRecoveryCodeUsed(ctx context.Context, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, codes := range r.recoveryCodes {
+		for i := range codes {
+			if codes[i].ID == id {
+				if codes[i].used {
+					return false, nil
+				}
+				codes[i].used = true
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r *memUserRepo) CreateWebAuthnCredential(ctx context.Context, cred *domain.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	cred.ID = fmt.Sprintf("cred-%d", r.nextID)
+	r.credentials[cred.UserID] = append(r.credentials[cred.UserID], *cred)
+	return nil
+}
+
+func (r *memUserRepo) GetWebAuthnCredentials(ctx context.Context, userID string) ([]domain.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.WebAuthnCredential(nil), r.credentials[userID]...), nil
+}
+
+func (r *memUserRepo) UpdateWebAuthnSignCount(ctx context.Context, id string, signCount uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, creds := range r.credentials {
+		for i := range creds {
+			if creds[i].ID == id {
+				creds[i].SignCount = signCount
+				return nil
+			}
+		}
+	}
+	return domain.ErrWebAuthnCredentialNotFound
+}
+
+func (r *memUserRepo) DeleteWebAuthnCredential(ctx context.Context, userID, id string) error {
+	return nil
+}
+
+func (r *memUserRepo) LogSecurityEvent(ctx context.Context, userID, eventType, ip string, metadata map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, memEvent{UserID: userID, Type: eventType, IP: ip, Metadata: metadata})
+	return nil
+}
+
+// eventTypes returns the sorted distinct audit events recorded so far.
+func (r *memUserRepo) eventTypes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := map[string]bool{}
+	for _, e := range r.events {
+		seen[e.Type] = true
+	}
+	var types []string
+	for t := range seen {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func (r *memUserRepo) hasEvent(eventType string) bool {
+	for _, t := range r.eventTypes() {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type nopMailer struct{}
+
+func (nopMailer) SendVerificationEmail(ctx context.Context, email, token string) error  { return nil }
+func (nopMailer) SendPasswordResetEmail(ctx context.Context, email, token string) error { return nil }
+func (nopMailer) SendAccountExistsEmail(ctx context.Context, email string) error        { return nil }
+
+// testEnv wires an AuthUsecase to the in-memory user store and Redis repositories
+// backed by miniredis.
+type testEnv struct {
+	auth  *AuthUsecase
+	users *memUserRepo
+	rdb   *redis.Client
+	redis *miniredis.Miniredis
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	users := newMemUserRepo()
+	auth := NewAuthUsecase(
+		users,
+		repository.NewRedisTokenRepo(rdb),
+		repository.NewRedisVerificationRepo(rdb),
+		repository.NewRedisMFAChallengeRepo(rdb),
+		repository.NewRedisLoginAttemptRepo(rdb),
+		nopMailer{},
+		Config{
+			JWTSecret:   "test-secret-that-is-long-enough-for-hs256",
+			BackoffBase: time.Millisecond,
+		},
+	)
+	return &testEnv{auth: auth, users: users, rdb: rdb, redis: mr}
+}
+
+// skipBackoff moves Redis time past the progressive delay that follows a failure.
+func (e *testEnv) skipBackoff() {
+	e.redis.FastForward(time.Second)
+}
+
+// createUser stores a verified account with the given password.
+func (e *testEnv) createUser(t *testing.T, email, password string) *domain.User {
+	t.Helper()
+	hash, err := security.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &domain.User{Email: strings.ToLower(email), PasswordHash: hash, Role: "user", EmailVerified: true}
+	if err := e.users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// totpCode is the software authenticator: it computes the code for secret at t.
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.GenerateCodeCustom(secret, at, totp.ValidateOpts{
+		Period:    security.DefaultTOTPConfig.Period,
+		Digits:    security.DefaultTOTPConfig.Digits,
+		Algorithm: security.DefaultTOTPConfig.Algorithm,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// nextStep returns a time inside the following TOTP step, which verification still
+// accepts thanks to the skew and which is newer than any step consumed so far.
+func nextStep(n int) time.Time {
+	return time.Now().Add(time.Duration(n) * time.Duration(security.DefaultTOTPConfig.Period) * time.Second)
+}
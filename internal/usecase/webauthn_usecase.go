@@ -0,0 +1,341 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/domain"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+var (
+	ErrInvalidPasskey     = errors.New("passkey verification failed")
+	ErrCredentialNotFound = errors.New("credential not found")
+)
+
+// webAuthnSessionTTL bounds how long a begin/finish ceremony may take.
+const webAuthnSessionTTL = 5 * time.Minute
+
+// WebAuthnUsecase implements passkey registration, passkeys as a second factor in the
+// Login -> ErrMFARequired flow, and passwordless login with discoverable credentials.
+type WebAuthnUsecase struct {
+	auth     *AuthUsecase
+	sessions domain.WebAuthnSessionRepository
+	passkeys *security.Passkeys
+}
+
+func NewWebAuthnUsecase(a *AuthUsecase, s domain.WebAuthnSessionRepository, p *security.Passkeys) *WebAuthnUsecase {
+	return &WebAuthnUsecase{
+		auth:     a,
+		sessions: s,
+		passkeys: p,
+	}
+}
+
+// BeginRegistration returns the credential creation options for the authenticated user.
+func (w *WebAuthnUsecase) BeginRegistration(ctx context.Context, userID string) (interface{}, error) {
+	user, pkUser, err := w.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	options, session, err := w.passkeys.BeginRegistration(pkUser)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.sessions.StoreWebAuthnSession(ctx, "register:"+user.ID, session, webAuthnSessionTTL); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// FinishRegistration verifies the attestation and stores the new passkey.
+func (w *WebAuthnUsecase) FinishRegistration(ctx context.Context, userID, name string, response []byte) (*domain.WebAuthnCredential, error) {
+	user, pkUser, err := w.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := w.sessions.ConsumeWebAuthnSession(ctx, "register:"+user.ID)
+	if err != nil {
+		if errors.Is(err, domain.ErrWebAuthnSessionNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	pk, err := w.passkeys.FinishRegistration(pkUser, session, response)
+	if err != nil {
+		_ = w.auth.userRepo.LogSecurityEvent(ctx, user.ID, "WEBAUTHN_REGISTER_FAILED", "", map[string]interface{}{
+			"reason": err.Error(),
+		})
+		return nil, ErrInvalidPasskey
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+	cred := &domain.WebAuthnCredential{
+		UserID:          user.ID,
+		CredentialID:    pk.ID,
+		PublicKey:       pk.PublicKey,
+		AttestationType: pk.AttestationType,
+		AAGUID:          pk.AAGUID,
+		SignCount:       pk.SignCount,
+		Transports:      pk.Transports,
+		Name:            name,
+	}
+	if err := w.auth.userRepo.CreateWebAuthnCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+
+	_ = w.auth.userRepo.LogSecurityEvent(ctx, user.ID, "WEBAUTHN_REGISTERED", "", map[string]interface{}{
+		"credential_id": cred.ID,
+		"attestation":   cred.AttestationType,
+	})
+
+	return cred, nil
+}
+
+// ListCredentials returns the passkeys registered by the user.
+func (w *WebAuthnUsecase) ListCredentials(ctx context.Context, userID string) ([]domain.WebAuthnCredential, error) {
+	return w.auth.userRepo.GetWebAuthnCredentials(ctx, userID)
+}
+
+// DeleteCredential removes one of the user's passkeys.
+func (w *WebAuthnUsecase) DeleteCredential(ctx context.Context, userID, id string) error {
+	if err := w.auth.userRepo.DeleteWebAuthnCredential(ctx, userID, id); err != nil {
+		if errors.Is(err, domain.ErrWebAuthnCredentialNotFound) {
+			return ErrCredentialNotFound
+		}
+		return err
+	}
+
+	_ = w.auth.userRepo.LogSecurityEvent(ctx, userID, "WEBAUTHN_REMOVED", "", map[string]interface{}{
+		"credential_id": id,
+	})
+
+	return nil
+}
+
+// BeginMFA returns assertion options for a pending MFA challenge issued by Login.
+func (w *WebAuthnUsecase) BeginMFA(ctx context.Context, mfaToken string) (interface{}, error) {
+	challenge, err := w.auth.challengeRepo.GetMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrMFAChallengeNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	_, pkUser, err := w.loadUser(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkUser.Credentials) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+
+	options, session, err := w.passkeys.BeginLogin(pkUser)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.sessions.StoreWebAuthnSession(ctx, "mfa:"+mfaToken, session, webAuthnSessionTTL); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// FinishMFA verifies the assertion as the second factor and completes the login.
+// Failures count against the challenge and the account lockout exactly like wrong TOTP codes.
+func (w *WebAuthnUsecase) FinishMFA(ctx context.Context, mfaToken string, response []byte, ip string) (*domain.AuthResponse, error) {
+	challenge, err := w.auth.challengeRepo.GetMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrMFAChallengeNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	user, pkUser, err := w.loadUser(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.auth.checkThrottle(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	session, err := w.sessions.ConsumeWebAuthnSession(ctx, "mfa:"+mfaToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrWebAuthnSessionNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	attempts, err := w.auth.reserveMFAAttempt(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := w.passkeys.FinishLogin(pkUser, session, response)
+	if err != nil {
+		w.logAssertionFailure(ctx, user.ID, ip, err)
+		w.auth.registerFailure(ctx, user.ID, user.Email, ip)
+		w.auth.exhaustMFAChallenge(ctx, mfaToken, user.ID, attempts)
+		return nil, ErrInvalidPasskey
+	}
+
+	// Consume the challenge; if a concurrent request got there first, refuse.
+	deleted, err := w.auth.challengeRepo.DeleteMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, ErrInvalidToken
+	}
+
+	if err := w.recordUsage(ctx, user.ID, pk); err != nil {
+		return nil, err
+	}
+	w.auth.clearFailures(ctx, user.Email)
+
+	return w.auth.generateSession(ctx, user)
+}
+
+// BeginLogin starts a passwordless login. The returned session token must be sent
+// back with the assertion.
+func (w *WebAuthnUsecase) BeginLogin(ctx context.Context) (interface{}, string, error) {
+	options, session, err := w.passkeys.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := w.sessions.StoreWebAuthnSession(ctx, "login:"+token, session, webAuthnSessionTTL); err != nil {
+		return nil, "", err
+	}
+
+	return options, token, nil
+}
+
+// FinishLogin verifies a discoverable credential assertion and issues a session.
+// The passkey (with user verification) replaces both the password and the second factor.
+// The account is only known once the assertion names it, so the lockout is enforced after
+// verification: a locked account cannot sign in with a passkey either.
+func (w *WebAuthnUsecase) FinishLogin(ctx context.Context, sessionToken string, response []byte, ip string) (*domain.AuthResponse, error) {
+	session, err := w.sessions.ConsumeWebAuthnSession(ctx, "login:"+sessionToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrWebAuthnSessionNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	var user *domain.User
+	lookup := func(userHandle []byte) (*security.PasskeyUser, error) {
+		var pkUser *security.PasskeyUser
+		var err error
+		user, pkUser, err = w.loadUser(ctx, string(userHandle))
+		return pkUser, err
+	}
+
+	_, pk, err := w.passkeys.FinishDiscoverableLogin(lookup, session, response)
+	if err != nil {
+		userID := ""
+		if user != nil {
+			userID = user.ID
+			w.auth.registerFailure(ctx, user.ID, user.Email, ip)
+		}
+		w.logAssertionFailure(ctx, userID, ip, err)
+		return nil, ErrInvalidPasskey
+	}
+
+	if err := w.auth.checkThrottle(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	if !user.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	if err := w.recordUsage(ctx, user.ID, pk); err != nil {
+		return nil, err
+	}
+
+	w.auth.clearFailures(ctx, user.Email)
+
+	_ = w.auth.userRepo.LogSecurityEvent(ctx, user.ID, "PASSKEY_LOGIN", ip, nil)
+
+	return w.auth.generateSession(ctx, user)
+}
+
+// loadUser fetches the account and its passkeys in the form expected by the ceremonies.
+// The WebAuthn user handle is the account UUID.
+func (w *WebAuthnUsecase) loadUser(ctx context.Context, userID string) (*domain.User, *security.PasskeyUser, error) {
+	user, err := w.auth.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, ErrUserNotFound
+	}
+
+	creds, err := w.auth.userRepo.GetWebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkUser := &security.PasskeyUser{
+		ID:          []byte(user.ID),
+		Name:        user.Email,
+		DisplayName: user.Email,
+		Credentials: make([]security.PasskeyCredential, len(creds)),
+	}
+	for i, c := range creds {
+		pkUser.Credentials[i] = security.PasskeyCredential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			AAGUID:          c.AAGUID,
+			SignCount:       c.SignCount,
+			Transports:      c.Transports,
+		}
+	}
+
+	return user, pkUser, nil
+}
+
+// recordUsage persists the new sign counter of the credential used in an assertion.
+func (w *WebAuthnUsecase) recordUsage(ctx context.Context, userID string, pk *security.PasskeyCredential) error {
+	creds, err := w.auth.userRepo.GetWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range creds {
+		if string(c.CredentialID) == string(pk.ID) {
+			return w.auth.userRepo.UpdateWebAuthnSignCount(ctx, c.ID, pk.SignCount)
+		}
+	}
+
+	return ErrCredentialNotFound
+}
+
+// logAssertionFailure audits a failed assertion, flagging suspected cloned authenticators.
+func (w *WebAuthnUsecase) logAssertionFailure(ctx context.Context, userID, ip string, err error) {
+	event := "WEBAUTHN_FAILED"
+	if errors.Is(err, security.ErrPasskeyCloneWarning) {
+		event = "WEBAUTHN_CLONE_WARNING"
+	}
+	_ = w.auth.userRepo.LogSecurityEvent(ctx, userID, event, ip, map[string]interface{}{
+		"reason": err.Error(),
+	})
+}
@@ -0,0 +1,403 @@
+package usecase
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+
+	"github.com/FilipeAphrody/sentinel-auth/internal/repository"
+	"github.com/FilipeAphrody/sentinel-auth/pkg/security"
+)
+
+const (
+	testRPID   = "auth.example.com"
+	testOrigin = "https://auth.example.com"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// softAuthenticator is a software passkey holding one ES256 credential. It answers the
+// options of the ceremonies with responses shaped like a browser's.
+type softAuthenticator struct {
+	t       *testing.T
+	key     *ecdsa.PrivateKey
+	credID  []byte
+	handle  []byte // user handle, returned by discoverable assertions
+	counter uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credID := make([]byte, 16)
+	if _, err := rand.Read(credID); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{t: t, key: key, credID: credID}
+}
+
+// register answers credential creation options with an attestation in the given format:
+// "none", "packed" (self attestation) or "fido-u2f".
+func (a *softAuthenticator) register(options interface{}, format string) []byte {
+	a.t.Helper()
+	creation := options.(*protocol.CredentialCreation)
+	a.handle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	clientData := a.clientData("webauthn.create", creation.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+
+	x := a.key.PublicKey.X.FillBytes(make([]byte, 32))
+	y := a.key.PublicKey.Y.FillBytes(make([]byte, 32))
+	coseKey, err := webauthncbor.Marshal(map[int]interface{}{1: 2, 3: -7, -1: 1, -2: x, -3: y})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	authData := a.authData(flagUserPresent|flagUserVerified|flagAttestedData, 0)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credID)))
+	authData = append(authData, a.credID...)
+	authData = append(authData, coseKey...)
+
+	statement := map[string]interface{}{}
+	switch format {
+	case "packed":
+		statement["alg"] = -7
+		statement["sig"] = a.sign(a.key, authData, clientDataHash[:])
+	case "fido-u2f":
+		// Attested by a separate self-signed P-256 certificate
+		attKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "U2F Test Attestation"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		cert, err := x509.CreateCertificate(rand.Reader, template, template, &attKey.PublicKey, attKey)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		rpIDHash := sha256.Sum256([]byte(testRPID))
+		signed := append([]byte{0}, rpIDHash[:]...)
+		signed = append(signed, clientDataHash[:]...)
+		signed = append(signed, a.credID...)
+		signed = append(append(signed, 0x04), append(x, y...)...)
+		statement["x5c"] = []interface{}{cert}
+		statement["sig"] = a.sign(attKey, signed)
+	}
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      format,
+		"attStmt":  statement,
+		"authData": authData,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.response(map[string]string{
+		"clientDataJSON":    b64(clientData),
+		"attestationObject": b64(attestation),
+	})
+}
+
+// assert answers assertion options, advancing the sign counter unless told otherwise.
+func (a *softAuthenticator) assert(options interface{}, flags byte) []byte {
+	a.t.Helper()
+	a.counter++
+	return a.assertWithCounter(options, flags, a.counter)
+}
+
+func (a *softAuthenticator) assertWithCounter(options interface{}, flags byte, counter uint32) []byte {
+	a.t.Helper()
+	assertion := options.(*protocol.CredentialAssertion)
+
+	clientData := a.clientData("webauthn.get", assertion.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	authData := a.authData(flags, counter)
+
+	return a.response(map[string]string{
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(a.sign(a.key, authData, clientDataHash[:])),
+		"userHandle":        b64(a.handle),
+	})
+}
+
+func (a *softAuthenticator) clientData(ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) authData(flags byte, counter uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, counter)
+}
+
+func (a *softAuthenticator) sign(key *ecdsa.PrivateKey, parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h.Sum(nil))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return sig
+}
+
+func (a *softAuthenticator) response(fields map[string]string) []byte {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":       b64(a.credID),
+		"rawId":    b64(a.credID),
+		"type":     "public-key",
+		"response": fields,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return body
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newTestWebAuthn(t *testing.T, env *testEnv) *WebAuthnUsecase {
+	t.Helper()
+	passkeys, err := security.NewPasskeys(testRPID, "Sentinel", []string{testOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWebAuthnUsecase(env.auth, repository.NewRedisWebAuthnSessionRepo(env.rdb), passkeys)
+}
+
+// registerPasskey runs the registration ceremony with the given attestation format.
+func registerPasskey(t *testing.T, w *WebAuthnUsecase, userID, format string) (*softAuthenticator, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	options, err := w.BeginRegistration(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := newSoftAuthenticator(t)
+	_, err = w.FinishRegistration(ctx, userID, "Test key", authenticator.register(options, format))
+	return authenticator, err
+}
+
+// passwordlessLogin runs a discoverable login with the given authenticator flags.
+func passwordlessLogin(t *testing.T, w *WebAuthnUsecase, a *softAuthenticator, flags byte) error {
+	t.Helper()
+	ctx := context.Background()
+
+	options, token, err := w.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.FinishLogin(ctx, token, a.assert(options, flags), "198.51.100.7")
+	return err
+}
+
+func TestPasskeyRegistrationFormats(t *testing.T) {
+	for _, format := range []string{"none", "packed"} {
+		t.Run(format, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			w := newTestWebAuthn(t, env)
+			user := env.createUser(t, "alice@example.com", testPassword)
+
+			authenticator, err := registerPasskey(t, w, user.ID, format)
+			if err != nil {
+				t.Fatalf("FinishRegistration: %v", err)
+			}
+			creds, _ := w.ListCredentials(ctx, user.ID)
+			if len(creds) != 1 || creds[0].AttestationType != format {
+				t.Fatalf("stored credentials = %+v", creds)
+			}
+
+			if err := passwordlessLogin(t, w, authenticator, flagUserPresent|flagUserVerified); err != nil {
+				t.Fatalf("login with the new passkey: %v", err)
+			}
+		})
+	}
+}
+
+func TestPasskeyRegistrationRejectsOtherFormats(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	w := newTestWebAuthn(t, env)
+	user := env.createUser(t, "bob@example.com", testPassword)
+
+	if _, err := registerPasskey(t, w, user.ID, "fido-u2f"); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("fido-u2f attestation: err = %v, want ErrInvalidPasskey", err)
+	}
+	if creds, _ := w.ListCredentials(ctx, user.ID); len(creds) != 0 {
+		t.Fatalf("credential stored despite the rejected format: %+v", creds)
+	}
+	// The attestation itself is valid: it is the format that is refused
+	var reason interface{}
+	for _, e := range env.users.events {
+		if e.Type == "WEBAUTHN_REGISTER_FAILED" {
+			reason = e.Metadata["reason"]
+		}
+	}
+	if reason != security.ErrUnsupportedAttestation.Error() {
+		t.Fatalf("registration failed with %v, want %v", reason, security.ErrUnsupportedAttestation)
+	}
+}
+
+func TestPasswordlessLoginRequiresUserVerification(t *testing.T) {
+	env := newTestEnv(t)
+	w := newTestWebAuthn(t, env)
+	user := env.createUser(t, "carol@example.com", testPassword)
+
+	authenticator, err := registerPasskey(t, w, user.ID, "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Presence alone does not replace the password
+	if err := passwordlessLogin(t, w, authenticator, flagUserPresent); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("without user verification: err = %v, want ErrInvalidPasskey", err)
+	}
+	if err := passwordlessLogin(t, w, authenticator, flagUserPresent|flagUserVerified); err != nil {
+		t.Fatalf("with user verification: %v", err)
+	}
+	if !env.users.hasEvent("PASSKEY_LOGIN") {
+		t.Fatal("passwordless login was not audited")
+	}
+}
+
+func TestPasskeyRejectsSignCounterRegression(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	w := newTestWebAuthn(t, env)
+	user := env.createUser(t, "dave@example.com", testPassword)
+
+	authenticator, err := registerPasskey(t, w, user.ID, "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.counter = 5
+	if err := passwordlessLogin(t, w, authenticator, flagUserPresent|flagUserVerified); err != nil {
+		t.Fatal(err)
+	}
+
+	// A clone still at an older counter value
+	options, token, err := w.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := authenticator.assertWithCounter(options, flagUserPresent|flagUserVerified, 3)
+	if _, err := w.FinishLogin(ctx, token, response, "198.51.100.7"); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("counter regression: err = %v, want ErrInvalidPasskey", err)
+	}
+	if !env.users.hasEvent("WEBAUTHN_CLONE_WARNING") {
+		t.Fatal("counter regression was not flagged as a clone")
+	}
+}
+
+func TestPasskeyAsSecondFactor(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	w := newTestWebAuthn(t, env)
+	user := env.createUser(t, "erin@example.com", testPassword)
+
+	authenticator, err := registerPasskey(t, w, user.ID, "packed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A registered passkey turns the password into the first of two steps
+	resp, err := env.auth.Login(ctx, user.Email, testPassword, "198.51.100.7")
+	if !errors.Is(err, ErrMFARequired) {
+		t.Fatalf("Login: err = %v, want ErrMFARequired", err)
+	}
+	if len(resp.MFAMethods) == 0 || resp.MFAToken == "" {
+		t.Fatalf("challenge = %+v", resp)
+	}
+
+	options, err := w.BeginMFA(ctx, resp.MFAToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := w.FinishMFA(ctx, resp.MFAToken, authenticator.assert(options, flagUserPresent), "198.51.100.7")
+	if err != nil {
+		t.Fatalf("FinishMFA: %v", err)
+	}
+	if _, err := env.auth.Authenticate(ctx, session.AccessToken); err != nil {
+		t.Fatalf("issued session: %v", err)
+	}
+
+	// The challenge is spent
+	if _, err := w.BeginMFA(ctx, resp.MFAToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("spent challenge: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestPasskeyLoginRespectsLockout(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	w := newTestWebAuthn(t, env)
+	user := env.createUser(t, "frank@example.com", testPassword)
+
+	authenticator, err := registerPasskey(t, w, user.ID, "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed assertions count towards the lockout like wrong passwords
+	for i := 1; i < env.auth.cfg.MaxAccountFailures; i++ {
+		failLogin(t, env, user.Email, "198.51.100.7")
+		env.skipBackoff()
+	}
+	if err := passwordlessLogin(t, w, authenticator, flagUserPresent); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("without user verification: err = %v, want ErrInvalidPasskey", err)
+	}
+
+	// A valid passkey does not get around the lock
+	if err := passwordlessLogin(t, w, authenticator, flagUserPresent|flagUserVerified); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("locked account: err = %v, want ErrAccountLocked", err)
+	}
+
+	if err := env.auth.UnlockAccount(ctx, "admin-1", user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := passwordlessLogin(t, w, authenticator, flagUserPresent|flagUserVerified); err != nil {
+		t.Fatalf("after unlock: %v", err)
+	}
+}
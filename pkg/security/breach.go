@@ -0,0 +1,234 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// breachIndexMagic starts every index written by BuildBreachIndex.
+const breachIndexMagic = "HIBPIDX1"
+
+// breachRecordSize is one index record: the 20-byte SHA-1 followed by a big-endian uint32 count.
+const breachRecordSize = sha1.Size + 4
+
+// --- Breached Passwords ---
+// BreachChecker looks passwords up in a local copy of the Have I Been Pwned corpus,
+// so no password (or hash prefix) ever leaves the server. It accepts either the
+// "SHA1:COUNT" text dump ordered by hash, or the compact index built from it by
+// BuildBreachIndex. Both are binary searched on disk and never loaded into memory.
+type BreachChecker struct {
+	f      *os.File
+	size   int64
+	binary bool
+}
+
+// OpenBreachCorpus opens a HIBP dump or index. The format is detected from the header.
+func OpenBreachCorpus(path string) (*BreachChecker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breach corpus: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open breach corpus: %w", err)
+	}
+
+	b := &BreachChecker{f: f, size: info.Size()}
+
+	header := make([]byte, len(breachIndexMagic))
+	if _, err := f.ReadAt(header, 0); err == nil && string(header) == breachIndexMagic {
+		if (b.size-int64(len(breachIndexMagic)))%breachRecordSize != 0 {
+			f.Close()
+			return nil, errors.New("breach index is truncated")
+		}
+		b.binary = true
+	}
+
+	return b, nil
+}
+
+// Close releases the corpus file.
+func (b *BreachChecker) Close() error {
+	return b.f.Close()
+}
+
+// Occurrences returns how many times the password appears in the corpus (0 if never).
+func (b *BreachChecker) Occurrences(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	if b.binary {
+		return b.searchIndex(sum[:])
+	}
+	return b.searchDump(strings.ToUpper(hex.EncodeToString(sum[:])))
+}
+
+// searchIndex binary searches the fixed-size records of a compact index.
+func (b *BreachChecker) searchIndex(hash []byte) (int, error) {
+	n := int((b.size - int64(len(breachIndexMagic))) / breachRecordSize)
+	record := make([]byte, breachRecordSize)
+
+	var readErr error
+	read := func(i int) []byte {
+		off := int64(len(breachIndexMagic)) + int64(i)*breachRecordSize
+		if _, err := b.f.ReadAt(record, off); err != nil {
+			readErr = err
+		}
+		return record
+	}
+
+	i := sort.Search(n, func(i int) bool {
+		return readErr != nil || bytes.Compare(read(i)[:sha1.Size], hash) >= 0
+	})
+	if i < n {
+		read(i)
+	}
+	if readErr != nil {
+		return 0, fmt.Errorf("failed to read breach index: %w", readErr)
+	}
+	if i == n || !bytes.Equal(record[:sha1.Size], hash) {
+		return 0, nil
+	}
+
+	return int(binary.BigEndian.Uint32(record[sha1.Size:])), nil
+}
+
+// searchDump binary searches the text dump by byte offset, re-aligning on line starts.
+func (b *BreachChecker) searchDump(hash string) (int, error) {
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start, line, err := b.lineAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if line == nil || start >= hi {
+			hi = mid
+			continue
+		}
+
+		entry, count, _ := strings.Cut(strings.TrimRight(string(line), "\r"), ":")
+		switch cmp := strings.Compare(strings.ToUpper(entry), hash); {
+		case cmp == 0:
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				return 0, fmt.Errorf("malformed breach corpus line: %q", line)
+			}
+			return n, nil
+		case cmp < 0:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+
+	return 0, nil
+}
+
+// lineAt returns the first complete line starting at or after off, or a nil line at EOF.
+func (b *BreachChecker) lineAt(off int64) (int64, []byte, error) {
+	const window = 256 // HIBP lines are ~45 bytes
+
+	// Read from one byte earlier to know whether off is already a line start
+	from := off
+	if off > 0 {
+		from = off - 1
+	}
+
+	buf := make([]byte, window)
+	n, err := b.f.ReadAt(buf, from)
+	if err != nil && err != io.EOF {
+		return 0, nil, fmt.Errorf("failed to read breach corpus: %w", err)
+	}
+	buf = buf[:n]
+
+	start := 0
+	if off > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return 0, nil, nil
+		}
+		start = i + 1
+	}
+	if start >= len(buf) {
+		return 0, nil, nil
+	}
+
+	line := buf[start:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	} else if from+int64(n) < b.size {
+		return 0, nil, errors.New("breach corpus line too long")
+	}
+
+	return from + int64(start), line, nil
+}
+
+// BuildBreachIndex converts a HIBP "SHA1:COUNT" dump ordered by hash into the compact
+// index read by OpenBreachCorpus: 24 bytes per hash instead of ~45, and fixed-size records.
+// Entries seen fewer than minCount times are dropped to shrink the index further.
+// It returns the number of hashes written.
+func BuildBreachIndex(dump io.Reader, index io.Writer, minCount int) (int, error) {
+	out := bufio.NewWriter(index)
+	if _, err := out.WriteString(breachIndexMagic); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	var prev []byte
+	record := make([]byte, breachRecordSize)
+
+	scanner := bufio.NewScanner(dump)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		entry, countStr, ok := strings.Cut(line, ":")
+		hash, err := hex.DecodeString(entry)
+		if !ok || err != nil || len(hash) != sha1.Size {
+			return written, fmt.Errorf("line %d: expected SHA1:COUNT", lineNo)
+		}
+		count, err := strconv.Atoi(countStr)
+		if err != nil {
+			return written, fmt.Errorf("line %d: invalid count", lineNo)
+		}
+
+		if prev != nil && bytes.Compare(hash, prev) <= 0 {
+			return written, fmt.Errorf("line %d: dump is not ordered by hash", lineNo)
+		}
+		prev = hash
+
+		if count < minCount {
+			continue
+		}
+		if count > math.MaxUint32 {
+			count = math.MaxUint32
+		}
+
+		copy(record, hash)
+		binary.BigEndian.PutUint32(record[sha1.Size:], uint32(count))
+		if _, err := out.Write(record); err != nil {
+			return written, err
+		}
+		written++
+	}
+	if err := scanner.Err(); err != nil {
+		return written, err
+	}
+
+	return written, out.Flush()
+}
@@ -0,0 +1,156 @@
+package security
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeBreachDump writes a HIBP-style dump holding the given passwords plus filler
+// hashes, ordered by hash, and returns its path.
+func writeBreachDump(t *testing.T, counts map[string]int, newline string) string {
+	t.Helper()
+
+	lines := make([]string, 0, len(counts)+500)
+	for password, count := range counts {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), count))
+	}
+	for i := 0; i < 500; i++ {
+		sum := sha1.Sum([]byte(fmt.Sprintf("filler-%d", i)))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), i%7+1))
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, newline)+newline), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// breachCorpusExtremes returns the passwords whose hashes sort first and last among
+// the filler, so the search boundaries are exercised.
+func breachCorpusExtremes() (first, last string) {
+	type entry struct{ hash, password string }
+	var entries []entry
+	for i := 0; i < 500; i++ {
+		password := fmt.Sprintf("filler-%d", i)
+		sum := sha1.Sum([]byte(password))
+		entries = append(entries, entry{hex.EncodeToString(sum[:]), password})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].hash < entries[j].hash })
+	return entries[0].password, entries[len(entries)-1].password
+}
+
+func TestBreachChecker(t *testing.T) {
+	counts := map[string]int{"password": 9545824, "hunter2": 17043, "rare-but-seen": 1}
+	first, last := breachCorpusExtremes()
+
+	dump := writeBreachDump(t, counts, "\n")
+	crlf := writeBreachDump(t, counts, "\r\n")
+
+	index := filepath.Join(t.TempDir(), "breach.idx")
+	src, err := os.Open(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := BuildBreachIndex(src, &buf, 1)
+	src.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(counts)+500 {
+		t.Fatalf("index holds %d hashes, want %d", n, len(counts)+500)
+	}
+	if err := os.WriteFile(index, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, path := range map[string]string{"dump": dump, "crlf dump": crlf, "index": index} {
+		t.Run(name, func(t *testing.T) {
+			checker, err := OpenBreachCorpus(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer checker.Close()
+
+			for password, want := range counts {
+				if got, err := checker.Occurrences(password); err != nil || got != want {
+					t.Errorf("Occurrences(%q) = %d, %v, want %d", password, got, err, want)
+				}
+			}
+			for _, password := range []string{first, last} {
+				if got, err := checker.Occurrences(password); err != nil || got == 0 {
+					t.Errorf("Occurrences(%q) = %d, %v, want > 0", password, got, err)
+				}
+			}
+			for _, password := range []string{"zebra-kettle-orbit", ""} {
+				if got, err := checker.Occurrences(password); err != nil || got != 0 {
+					t.Errorf("Occurrences(%q) = %d, %v, want 0", password, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildBreachIndex(t *testing.T) {
+	a := "0000000000000000000000000000000000000001"
+	b := "0000000000000000000000000000000000000002"
+
+	var buf bytes.Buffer
+	n, err := BuildBreachIndex(strings.NewReader(a+":1\n"+b+":5\n"), &buf, 2)
+	if err != nil || n != 1 {
+		t.Fatalf("min count: n = %d, err = %v, want 1 hash", n, err)
+	}
+	if got, want := buf.Len(), len(breachIndexMagic)+breachRecordSize; got != want {
+		t.Fatalf("index is %d bytes, want %d", got, want)
+	}
+
+	for name, dump := range map[string]string{
+		"unordered": b + ":1\n" + a + ":1\n",
+		"duplicate": a + ":1\n" + a + ":1\n",
+		"malformed": "not-a-hash:1\n",
+		"no count":  a + "\n",
+	} {
+		if _, err := BuildBreachIndex(strings.NewReader(dump), &bytes.Buffer{}, 1); err == nil {
+			t.Errorf("%s dump accepted", name)
+		}
+	}
+}
+
+func TestPasswordPolicyBreached(t *testing.T) {
+	checker, err := OpenBreachCorpus(writeBreachDump(t, map[string]int{"zebra-kettle-orbit": 3}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checker.Close()
+
+	policy := DefaultPasswordPolicy
+	policy.Breaches = checker
+	policy.BreachThreshold = 3
+
+	if count, breached := policy.Breached("zebra-kettle-orbit"); !breached || count != 3 {
+		t.Fatalf("Breached = %d, %v, want 3, true", count, breached)
+	}
+	if len(policy.Check("zebra-kettle-orbit", "")) != 0 {
+		t.Fatal("flag mode rejected a breached password")
+	}
+
+	policy.RejectBreached = true
+	if got := policy.Check("zebra-kettle-orbit", ""); len(got) != 1 || got[0] != ViolationBreached {
+		t.Fatalf("Check = %v, want [%s]", got, ViolationBreached)
+	}
+
+	policy.BreachThreshold = 4
+	if _, breached := policy.Breached("zebra-kettle-orbit"); breached {
+		t.Fatal("password below the threshold counted as breached")
+	}
+}
@@ -0,0 +1,196 @@
+package security
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrHashPoolBusy is returned when no hashing slot frees up within the queue timeout.
+var ErrHashPoolBusy = errors.New("password hashing capacity exhausted")
+
+// DefaultHashQueueTimeout is how long a hash waits for a slot before giving up.
+const DefaultHashQueueTimeout = 2 * time.Second
+
+// hashMemoryShare is the fraction of available memory Argon2id may use at once;
+// the rest is left to the Go runtime, Redis/Postgres clients and request handling.
+const hashMemoryShare = 0.5
+
+// --- Hashing Worker Pool ---
+// Every Argon2id (and scrypt) computation allocates its full memory cost up front, 64MB
+// with the default parameters. HashPool bounds how many run at once so a burst of logins
+// queues instead of exhausting the container's memory, and sheds load with
+// ErrHashPoolBusy when the queue does not drain in time.
+type HashPool struct {
+	slots   chan struct{}
+	timeout time.Duration
+
+	waiting   atomic.Int64
+	completed atomic.Uint64
+	timeouts  atomic.Uint64
+	waitTotal atomic.Int64 // nanoseconds
+	waitMax   atomic.Int64 // nanoseconds
+}
+
+// HashPoolStats is a snapshot of the pool for monitoring.
+type HashPoolStats struct {
+	Concurrency      int     `json:"concurrency"`
+	InUse            int     `json:"in_use"`
+	QueueDepth       int64   `json:"queue_depth"`
+	Completed        uint64  `json:"completed_total"`
+	Timeouts         uint64  `json:"timeouts_total"`
+	WaitSecondsTotal float64 `json:"wait_seconds_total"`
+	WaitSecondsMax   float64 `json:"wait_seconds_max"`
+}
+
+// DefaultHashPool is used by HashPassword and ComparePassword. It is sized for the
+// default parameters; replace it after changing DefaultParams.Memory.
+var DefaultHashPool = NewHashPool(AutoHashConcurrency(DefaultParams.Memory), DefaultHashQueueTimeout)
+
+// NewHashPool returns a pool running at most concurrency hashes at once. Callers wait up
+// to timeout for a slot.
+func NewHashPool(concurrency int, timeout time.Duration) *HashPool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if timeout <= 0 {
+		timeout = DefaultHashQueueTimeout
+	}
+	return &HashPool{
+		slots:   make(chan struct{}, concurrency),
+		timeout: timeout,
+	}
+}
+
+// Do runs fn once a slot is free, or returns ErrHashPoolBusy after the queue timeout.
+func (p *HashPool) Do(fn func()) error {
+	start := time.Now()
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		// Slow path: queue for a slot
+		p.waiting.Add(1)
+		timer := time.NewTimer(p.timeout)
+		select {
+		case p.slots <- struct{}{}:
+			timer.Stop()
+			p.waiting.Add(-1)
+		case <-timer.C:
+			p.waiting.Add(-1)
+			p.timeouts.Add(1)
+			return ErrHashPoolBusy
+		}
+	}
+	defer func() { <-p.slots }()
+
+	p.recordWait(time.Since(start))
+	fn()
+	p.completed.Add(1)
+	return nil
+}
+
+// RetryAfter suggests how long a rejected client should wait: one queue timeout.
+func (p *HashPool) RetryAfter() time.Duration {
+	return p.timeout
+}
+
+// Stats returns the current queue depth, slot usage and accumulated wait times.
+func (p *HashPool) Stats() HashPoolStats {
+	return HashPoolStats{
+		Concurrency:      cap(p.slots),
+		InUse:            len(p.slots),
+		QueueDepth:       p.waiting.Load(),
+		Completed:        p.completed.Load(),
+		Timeouts:         p.timeouts.Load(),
+		WaitSecondsTotal: time.Duration(p.waitTotal.Load()).Seconds(),
+		WaitSecondsMax:   time.Duration(p.waitMax.Load()).Seconds(),
+	}
+}
+
+func (p *HashPool) recordWait(d time.Duration) {
+	p.waitTotal.Add(int64(d))
+	for {
+		max := p.waitMax.Load()
+		if int64(d) <= max || p.waitMax.CompareAndSwap(max, int64(d)) {
+			return
+		}
+	}
+}
+
+// AutoHashConcurrency derives a concurrency limit from the memory available to the
+// process (GOMEMLIMIT, the cgroup limit or physical memory, whichever is known first)
+// and the Argon2id memory cost in KiB. The result is at least 1 and at most GOMAXPROCS,
+// since more parallel hashes than CPUs only adds latency.
+func AutoHashConcurrency(memoryKiB uint32) int {
+	limit := runtime.GOMAXPROCS(0)
+
+	available := availableMemory()
+	if available == 0 || memoryKiB == 0 {
+		return limit
+	}
+
+	n := int(float64(available) * hashMemoryShare / (float64(memoryKiB) * 1024))
+	if n < 1 {
+		return 1
+	}
+	if n > limit {
+		return limit
+	}
+	return n
+}
+
+// availableMemory returns the memory the process may use in bytes, or 0 if unknown.
+func availableMemory() uint64 {
+	if limit := debug.SetMemoryLimit(-1); limit > 0 && limit < math.MaxInt64 {
+		return uint64(limit)
+	}
+
+	total := physicalMemory()
+
+	// cgroup v2, then v1. An unlimited v1 cgroup reports a huge value, hence the cap.
+	for _, path := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			continue // "max" means unlimited
+		}
+		if total == 0 || limit < total {
+			return limit
+		}
+	}
+
+	return total
+}
+
+// physicalMemory reads MemTotal from /proc/meminfo, or returns 0 where it is unavailable.
+func physicalMemory() uint64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb * 1024
+		}
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package security
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// occupy holds one slot of the pool until the returned function is called.
+func occupy(t *testing.T, p *HashPool) func() {
+	t.Helper()
+	started, release, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = p.Do(func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+	return func() {
+		close(release)
+		<-done
+	}
+}
+
+func TestHashPoolSheds(t *testing.T) {
+	p := NewHashPool(1, 20*time.Millisecond)
+	release := occupy(t, p)
+
+	if s := p.Stats(); s.Concurrency != 1 || s.InUse != 1 {
+		t.Fatalf("stats with the slot taken = %+v", s)
+	}
+
+	ran := false
+	if err := p.Do(func() { ran = true }); !errors.Is(err, ErrHashPoolBusy) {
+		t.Fatalf("saturated pool: err = %v, want ErrHashPoolBusy", err)
+	}
+	if ran {
+		t.Fatal("rejected work ran anyway")
+	}
+	if p.RetryAfter() != 20*time.Millisecond {
+		t.Fatalf("RetryAfter = %v, want the queue timeout", p.RetryAfter())
+	}
+
+	release()
+	s := p.Stats()
+	if s.InUse != 0 || s.QueueDepth != 0 || s.Completed != 1 || s.Timeouts != 1 {
+		t.Fatalf("stats after release = %+v", s)
+	}
+}
+
+func TestHashPoolQueues(t *testing.T) {
+	p := NewHashPool(1, time.Second)
+	release := occupy(t, p)
+
+	done := make(chan error)
+	go func() { done <- p.Do(func() {}) }()
+
+	// The second hash waits in the queue instead of failing
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().QueueDepth != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("second hash never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	release()
+
+	if err := <-done; err != nil {
+		t.Fatalf("queued hash: %v", err)
+	}
+	s := p.Stats()
+	if s.QueueDepth != 0 || s.Completed != 2 || s.Timeouts != 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+	if s.WaitSecondsMax < 0.01 || s.WaitSecondsTotal < s.WaitSecondsMax {
+		t.Fatalf("wait times not recorded: %+v", s)
+	}
+}
@@ -0,0 +1,80 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// JSONWebKey is the public half of a signing key as published in a JWKS (RFC 7517).
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWK returns the public key in JWK form, or false for HMAC secrets.
+func (k *SigningKey) JWK() (JSONWebKey, bool) {
+	jwk := JSONWebKey{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	switch pub := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JSONWebKey{}, false
+	}
+
+	return jwk, true
+}
+
+// JWKS lists the public keys of the keyring: the signing key first, then every key still
+// accepted for verification, ordered by kid. Shared HMAC secrets are never included.
+func (r *Keyring) JWKS() JSONWebKeySet {
+	keys := r.Keys()
+	current := r.Current()
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == current) != (keys[j] == current) {
+			return keys[i] == current
+		}
+		return keys[i].ID < keys[j].ID
+	})
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, k := range keys {
+		if jwk, ok := k.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
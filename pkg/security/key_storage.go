@@ -0,0 +1,118 @@
+package security
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// --- Key Generation & Storage ---
+// Keys created by the key manager are stored as PKCS#8 sealed with AES-256-GCM under a
+// key encryption key (KEK) that never leaves the application configuration. The kid is
+// bound as additional data, so a ciphertext cannot be moved to another row.
+
+// rsaGeneratedBits is the modulus size of generated RS256 keys.
+const rsaGeneratedBits = 3072
+
+// GenerateSigningKey creates a new private key for alg: RS256, ES256, ES384, ES512 or EdDSA.
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch alg {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, rsaGeneratedBits)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		private, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		private, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(private)
+}
+
+// SealSigningKey encrypts the private key with kek (32 bytes) for storage.
+func SealSigningKey(key *SigningKey, kek []byte) ([]byte, error) {
+	if !key.CanSign() {
+		return nil, errors.New("signing key must include the private key")
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newKeyCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, der, []byte(key.ID)), nil
+}
+
+// OpenSigningKey decrypts a key sealed by SealSigningKey and checks it still has the given kid.
+func OpenSigningKey(kid string, sealed, kek []byte) (*SigningKey, error) {
+	aead, err := newKeyCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed signing key is truncated")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	der, err := aead.Open(nil, nonce, ciphertext, []byte(kid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt signing key %s: %w", kid, err)
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+
+	key, err := NewSigningKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	if key.ID != kid {
+		return nil, fmt.Errorf("signing key %s does not match its kid", kid)
+	}
+	return key, nil
+}
+
+func newKeyCipher(kek []byte) (cipher.AEAD, error) {
+	if len(kek) != 32 {
+		return nil, errors.New("key encryption key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
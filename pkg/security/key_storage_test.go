@@ -0,0 +1,76 @@
+package security
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSealSigningKeyRoundTrip(t *testing.T) {
+	kek := bytes.Repeat([]byte{7}, 32)
+
+	for _, alg := range []string{"ES256", "ES384", "ES512", "EdDSA"} {
+		key, err := GenerateSigningKey(alg)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		sealed, err := SealSigningKey(key, kek)
+		if err != nil {
+			t.Fatalf("%s: seal: %v", alg, err)
+		}
+
+		opened, err := OpenSigningKey(key.ID, sealed, kek)
+		if err != nil {
+			t.Fatalf("%s: open: %v", alg, err)
+		}
+		if opened.ID != key.ID || opened.Method.Alg() != alg || !opened.CanSign() {
+			t.Fatalf("%s: opened key = %s/%s, want %s", alg, opened.ID, opened.Method.Alg(), key.ID)
+		}
+	}
+}
+
+func TestOpenSigningKeyRejectsTampering(t *testing.T) {
+	kek := bytes.Repeat([]byte{7}, 32)
+	key, err := GenerateSigningKey("ES256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := SealSigningKey(key, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[len(flipped)-1] ^= 1
+
+	cases := map[string]struct {
+		kid    string
+		sealed []byte
+		kek    []byte
+	}{
+		"wrong kek":   {key.ID, sealed, bytes.Repeat([]byte{8}, 32)},
+		"short kek":   {key.ID, sealed, kek[:16]},
+		"other kid":   {"another-kid", sealed, kek},
+		"flipped bit": {key.ID, flipped, kek},
+		"truncated":   {key.ID, sealed[:8], kek},
+		"empty":       {key.ID, nil, kek},
+	}
+	for name, c := range cases {
+		if _, err := OpenSigningKey(c.kid, c.sealed, c.kek); err == nil {
+			t.Errorf("%s: opened without error", name)
+		}
+	}
+}
+
+func TestSealSigningKeyNeedsPrivateKey(t *testing.T) {
+	key, err := GenerateSigningKey("ES256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := NewVerificationKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SealSigningKey(public, bytes.Repeat([]byte{7}, 32)); err == nil {
+		t.Fatal("sealed a verification-only key")
+	}
+}
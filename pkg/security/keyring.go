@@ -0,0 +1,304 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA modulus accepted for token signing.
+const minRSABits = 2048
+
+// --- Token Signing Keys ---
+// A SigningKey signs and/or verifies access tokens. Asymmetric keys (RS256, ES256,
+// EdDSA) let other services validate tokens with the public half only; the shared
+// HS256 secret is kept for single-service deployments.
+type SigningKey struct {
+	ID     string // "kid" header of the tokens it signs
+	Method jwt.SigningMethod
+
+	private interface{} // private key or HMAC secret; nil for verification-only keys
+	public  interface{} // public key or HMAC secret
+}
+
+// CanSign reports whether the key holds private material.
+func (k *SigningKey) CanSign() bool {
+	return k.private != nil
+}
+
+// Public returns the public key, or nil for HMAC secrets, which must never be published.
+func (k *SigningKey) Public() crypto.PublicKey {
+	if _, ok := k.Method.(*jwt.SigningMethodHMAC); ok {
+		return nil
+	}
+	return k.public
+}
+
+// NewHMACKey wraps a shared secret as an HS256 key. Its ID is derived from the secret
+// through HMAC, so the kid does not reveal anything about it.
+func NewHMACKey(secret string) *SigningKey {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("sentinel-auth kid"))
+	return &SigningKey{
+		ID:      "hs-" + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:9]),
+		Method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// NewSigningKey wraps an RSA, ECDSA or Ed25519 private key. The algorithm follows from
+// the key type (RS256, ES256/ES384/ES512 by curve, EdDSA) and the ID from its public key.
+func NewSigningKey(private crypto.Signer) (*SigningKey, error) {
+	key, err := NewVerificationKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.private = private
+	return key, nil
+}
+
+// NewVerificationKey wraps a public key that may only verify tokens, e.g. the key of a
+// retired signer whose tokens have not expired yet.
+func NewVerificationKey(public crypto.PublicKey) (*SigningKey, error) {
+	var method jwt.SigningMethod
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+		}
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			method = jwt.SigningMethodES256
+		case elliptic.P384():
+			method = jwt.SigningMethodES384
+		case elliptic.P521():
+			method = jwt.SigningMethodES512
+		default:
+			return nil, errors.New("unsupported ECDSA curve")
+		}
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	return &SigningKey{
+		ID:     base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Method: method,
+		public: public,
+	}, nil
+}
+
+// ParseKeyPEM reads a PEM private key (PKCS#8, PKCS#1 or SEC 1) or public key (PKIX or
+// PKCS#1). Public keys become verification-only keys.
+func ParseKeyPEM(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return NewSigningKey(signer)
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(key)
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(key)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewVerificationKey(key)
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewVerificationKey(key)
+	}
+
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// LoadKeyFile reads a PEM key from disk; see ParseKeyPEM.
+func LoadKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key, err := ParseKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// --- Keyring ---
+// Keyring holds the key that signs new tokens and every key still accepted when
+// verifying them, indexed by kid. It is safe for concurrent use, so keys can be
+// rotated while the server is running.
+type Keyring struct {
+	mu      sync.RWMutex
+	current *SigningKey
+	keys    map[string]*SigningKey
+}
+
+// NewKeyring returns a keyring that signs with current.
+func NewKeyring(current *SigningKey) (*Keyring, error) {
+	r := &Keyring{keys: make(map[string]*SigningKey)}
+	if err := r.SetCurrent(current); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewHMACKeyring returns a keyring that signs and verifies with a shared HS256 secret.
+func NewHMACKeyring(secret string) *Keyring {
+	key := NewHMACKey(secret)
+	return &Keyring{current: key, keys: map[string]*SigningKey{key.ID: key}}
+}
+
+// SetCurrent makes key the signing key. It stays accepted for verification afterwards.
+func (r *Keyring) SetCurrent(key *SigningKey) error {
+	if key == nil || !key.CanSign() {
+		return errors.New("signing key must include the private key")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = key
+	r.keys[key.ID] = key
+	return nil
+}
+
+// Reset replaces the whole keyring in one step: current signs, current and verify are
+// accepted. Used when the key set is reloaded from shared storage.
+func (r *Keyring) Reset(current *SigningKey, verify ...*SigningKey) error {
+	if current == nil || !current.CanSign() {
+		return errors.New("signing key must include the private key")
+	}
+
+	keys := map[string]*SigningKey{current.ID: current}
+	for _, k := range verify {
+		keys[k.ID] = k
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = current
+	r.keys = keys
+	return nil
+}
+
+// Add accepts tokens signed by key without signing with it.
+func (r *Keyring) Add(key *SigningKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[key.ID] = key
+}
+
+// Remove stops accepting tokens signed by the key with the given ID.
+// The current signing key cannot be removed.
+func (r *Keyring) Remove(kid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current != nil && r.current.ID == kid {
+		return
+	}
+	delete(r.keys, kid)
+}
+
+// Current returns the signing key.
+func (r *Keyring) Current() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Keys returns every key accepted for verification, the signing key included.
+func (r *Keyring) Keys() []*SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*SigningKey, 0, len(r.keys))
+	for _, k := range r.keys {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// sign signs claims with the current key and sets its kid header.
+func (r *Keyring) sign(claims jwt.Claims) (string, error) {
+	key := r.Current()
+	if key == nil {
+		return "", errors.New("no signing key configured")
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// keyfunc selects the verification key by the kid header and refuses any algorithm
+// other than the one the key was registered with. Tokens without a kid predate key
+// IDs and are checked against the current key.
+func (r *Keyring) keyfunc(token *jwt.Token) (interface{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var key *SigningKey
+	switch kid, _ := token.Header["kid"].(string); {
+	case kid != "":
+		key = r.keys[kid]
+	default:
+		key = r.current
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown key id %v", token.Header["kid"])
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
@@ -0,0 +1,191 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testKeyPEMs returns one private key of each supported type, PEM-encoded the way
+// openssl writes them, keyed by the algorithm it signs with.
+func testKeyPEMs(t *testing.T) map[string][]byte {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string][]byte{
+		"RS256": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		"ES256": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}),
+		"EdDSA": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}),
+	}
+}
+
+// publicKeyPEM encodes the public half of key as a PKIX "PUBLIC KEY" block.
+func publicKeyPEM(t *testing.T, key *SigningKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestKeyringSignAndVerify(t *testing.T) {
+	for alg, data := range testKeyPEMs(t) {
+		t.Run(alg, func(t *testing.T) {
+			key, err := ParseKeyPEM(data)
+			if err != nil {
+				t.Fatalf("ParseKeyPEM: %v", err)
+			}
+			if key.Method.Alg() != alg || !key.CanSign() {
+				t.Fatalf("key = %s, can sign %v", key.Method.Alg(), key.CanSign())
+			}
+			keys, err := NewKeyring(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := GenerateClientAccessToken("user-1", "user", "client-1", "profile", keys, time.Minute)
+			if err != nil {
+				t.Fatalf("GenerateClientAccessToken: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["kid"] != key.ID || parsed.Header["alg"] != alg {
+				t.Fatalf("header = %v, want kid %q and alg %q", parsed.Header, key.ID, alg)
+			}
+
+			claims, err := ValidateToken(token, keys)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if claims.UserID != "user-1" || claims.ClientID != "client-1" || claims.Scope != "profile" || claims.Issuer != TokenIssuer {
+				t.Fatalf("claims = %+v", claims)
+			}
+
+			// Another service verifies with the public key alone
+			public, err := ParseKeyPEM(publicKeyPEM(t, key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if public.CanSign() || public.ID != key.ID {
+				t.Fatalf("public key: can sign %v, kid %q", public.CanSign(), public.ID)
+			}
+			verifier := NewHMACKeyring("unrelated secret")
+			verifier.Add(public)
+			if _, err := ValidateToken(token, verifier); err != nil {
+				t.Fatalf("verification with the public key: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyringRejectsUnknownKid(t *testing.T) {
+	pems := testKeyPEMs(t)
+	signer, _ := ParseKeyPEM(pems["ES256"])
+	other, _ := ParseKeyPEM(pems["EdDSA"])
+
+	issuing, _ := NewKeyring(signer)
+	token, err := GenerateAccessToken("user-1", "user", issuing, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifying, _ := NewKeyring(other)
+	if _, err := ValidateToken(token, verifying); err == nil {
+		t.Fatal("token signed by an unknown kid accepted")
+	}
+
+	// Once a retired key is removed its tokens stop validating
+	issuing.Add(other)
+	if err := issuing.SetCurrent(other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(token, issuing); err != nil {
+		t.Fatalf("token of the previous key: %v", err)
+	}
+	issuing.Remove(signer.ID)
+	if _, err := ValidateToken(token, issuing); err == nil {
+		t.Fatal("token of a removed key accepted")
+	}
+}
+
+func TestKeyringRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, err := ParseKeyPEM(testKeyPEMs(t)["RS256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := NewKeyring(rsaKey)
+	claims := Claims{
+		UserID: "attacker",
+		Role:   "admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			Issuer:    TokenIssuer,
+		},
+	}
+
+	forge := func(method jwt.SigningMethod, key interface{}) string {
+		t.Helper()
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = rsaKey.ID
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	// The public key is public: an HMAC made with it must not pass for an RS256 signature
+	publicPEM := publicKeyPEM(t, rsaKey)
+	der, _ := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	forged := map[string]string{
+		"HS256 with public PEM": forge(jwt.SigningMethodHS256, publicPEM),
+		"HS256 with public DER": forge(jwt.SigningMethodHS256, der),
+		"none":                  forge(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType),
+		"PS256 with the key":    forge(jwt.SigningMethodPS256, rsaKey.private.(crypto.Signer)),
+	}
+	for name, token := range forged {
+		if _, err := ValidateToken(token, keys); err == nil {
+			t.Errorf("%s: forged token accepted", name)
+		}
+	}
+
+	// Without a kid the current key applies, with the same algorithm check
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	unkeyed, _ := token.SignedString(publicPEM)
+	if _, err := ValidateToken(unkeyed, keys); err == nil {
+		t.Error("HS256 token without kid accepted by an RS256 keyring")
+	}
+}
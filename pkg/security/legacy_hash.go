@@ -0,0 +1,253 @@
+package security
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// --- Legacy Password Hashes ---
+// Accounts imported from older systems keep their original hash until the next successful
+// login, when NeedsRehash reports true and the password is re-hashed with Argon2id.
+// Supported formats:
+//
+//	$2a$ / $2b$ / $2y$              bcrypt
+//	$scrypt$ln=..,r=..,p=..$s$h     scrypt (passlib / PHC)
+//	$pbkdf2[-sha256|-sha512]$..     PBKDF2 (passlib "rounds" or PHC "i=..,l=..")
+//	pbkdf2_sha256$ / pbkdf2_sha1$   Django PBKDF2
+//	bcrypt_sha256$ / bcrypt$        Django bcrypt
+//	scrypt$                         Django scrypt
+//	argon2$argon2id$                Django Argon2id
+
+var errUnknownHashFormat = errors.New("unknown password hash format")
+
+// legacySchemes maps hash prefixes to scheme names, longest prefixes first where they overlap.
+var legacySchemes = []struct {
+	prefix string
+	scheme string
+}{
+	{"$2a$", "bcrypt"},
+	{"$2b$", "bcrypt"},
+	{"$2y$", "bcrypt"},
+	{"$scrypt$", "scrypt"},
+	{"$pbkdf2-sha256$", "pbkdf2-sha256"},
+	{"$pbkdf2-sha512$", "pbkdf2-sha512"},
+	{"$pbkdf2$", "pbkdf2-sha1"},
+	{"pbkdf2_sha256$", "django-pbkdf2-sha256"},
+	{"pbkdf2_sha1$", "django-pbkdf2-sha1"},
+	{"bcrypt_sha256$", "django-bcrypt-sha256"},
+	{"bcrypt$", "django-bcrypt"},
+	{"scrypt$", "django-scrypt"},
+	{"argon2$", "django-argon2"},
+}
+
+// HashScheme names the algorithm of an encoded password hash: "argon2id" for native
+// hashes, one of the legacy schemes above, or "unknown". Only the prefix is inspected,
+// so a bare prefix such as "$2b$" is enough.
+func HashScheme(encodedHash string) string {
+	if strings.HasPrefix(encodedHash, "$argon2id$") {
+		return "argon2id"
+	}
+	for _, s := range legacySchemes {
+		if strings.HasPrefix(encodedHash, s.prefix) {
+			return s.scheme
+		}
+	}
+	return "unknown"
+}
+
+// compareLegacyPassword verifies a password against a non-native hash.
+func compareLegacyPassword(password, encodedHash string) (bool, error) {
+	switch HashScheme(encodedHash) {
+	case "bcrypt":
+		return compareBcrypt([]byte(password), encodedHash)
+	case "scrypt":
+		return comparePHCScrypt(password, encodedHash)
+	case "pbkdf2-sha256":
+		return comparePHCPBKDF2(password, encodedHash, sha256.New)
+	case "pbkdf2-sha512":
+		return comparePHCPBKDF2(password, encodedHash, sha512.New)
+	case "pbkdf2-sha1":
+		return comparePHCPBKDF2(password, encodedHash, sha1.New)
+	case "django-pbkdf2-sha256":
+		return compareDjangoPBKDF2(password, encodedHash, sha256.New)
+	case "django-pbkdf2-sha1":
+		return compareDjangoPBKDF2(password, encodedHash, sha1.New)
+	case "django-bcrypt-sha256":
+		// Django pre-hashes with SHA-256 to get past bcrypt's 72-byte limit
+		sum := sha256.Sum256([]byte(password))
+		return compareBcrypt([]byte(hex.EncodeToString(sum[:])), strings.TrimPrefix(encodedHash, "bcrypt_sha256$"))
+	case "django-bcrypt":
+		return compareBcrypt([]byte(password), strings.TrimPrefix(encodedHash, "bcrypt$"))
+	case "django-scrypt":
+		return compareDjangoScrypt(password, encodedHash)
+	case "django-argon2":
+		inner := strings.TrimPrefix(encodedHash, "argon2")
+		if !strings.HasPrefix(inner, "$argon2id$") {
+			return false, errUnknownHashFormat
+		}
+		return ComparePassword(password, inner)
+	}
+	return false, errUnknownHashFormat
+}
+
+func compareBcrypt(password []byte, encodedHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), password)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return false, err
+}
+
+// comparePHCScrypt verifies "$scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<hash>".
+func comparePHCScrypt(password, encodedHash string) (bool, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 {
+		return false, errUnknownHashFormat
+	}
+
+	var ln, r, p int
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &ln, &r, &p); err != nil {
+		return false, err
+	}
+
+	salt, err := decodeAdaptedBase64(parts[3])
+	if err != nil {
+		return false, err
+	}
+	expected, err := decodeAdaptedBase64(parts[4])
+	if err != nil {
+		return false, err
+	}
+
+	key, err := poolScrypt([]byte(password), salt, 1<<ln, r, p, len(expected))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(expected, key) == 1, nil
+}
+
+// comparePHCPBKDF2 verifies "$pbkdf2-<digest>$<rounds>$<salt>$<hash>" (passlib)
+// and "$pbkdf2-<digest>$i=<rounds>,l=<len>$<salt>$<hash>" (PHC).
+func comparePHCPBKDF2(password, encodedHash string, h func() hash.Hash) (bool, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 {
+		return false, errUnknownHashFormat
+	}
+
+	rounds := 0
+	for _, param := range strings.Split(parts[2], ",") {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			value = key
+		} else if key != "i" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return false, errUnknownHashFormat
+		}
+		rounds = n
+	}
+
+	salt, err := decodeAdaptedBase64(parts[3])
+	if err != nil {
+		return false, err
+	}
+	expected, err := decodeAdaptedBase64(parts[4])
+	if err != nil {
+		return false, err
+	}
+
+	key, err := pbkdf2.Key(h, password, salt, rounds, len(expected))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(expected, key) == 1, nil
+}
+
+// compareDjangoPBKDF2 verifies "pbkdf2_<digest>$<iterations>$<salt>$<base64 hash>".
+// Django uses the salt string as-is.
+func compareDjangoPBKDF2(password, encodedHash string, h func() hash.Hash) (bool, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 4 {
+		return false, errUnknownHashFormat
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false, errUnknownHashFormat
+	}
+	expected, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, err
+	}
+
+	key, err := pbkdf2.Key(h, password, []byte(parts[2]), iterations, len(expected))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(expected, key) == 1, nil
+}
+
+// compareDjangoScrypt verifies "scrypt$<N>$<salt>$<r>$<p>$<base64 hash>".
+// Django uses the salt string as-is.
+func compareDjangoScrypt(password, encodedHash string) (bool, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 {
+		return false, errUnknownHashFormat
+	}
+
+	var params [3]int // N, r, p
+	for i, v := range []string{parts[1], parts[3], parts[4]} {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return false, errUnknownHashFormat
+		}
+		params[i] = n
+	}
+	expected, err := base64.StdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, err
+	}
+
+	key, err := poolScrypt([]byte(password), []byte(parts[2]), params[0], params[1], params[2], len(expected))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(expected, key) == 1, nil
+}
+
+// poolScrypt runs scrypt in the hashing pool: like Argon2id, it allocates 128*N*r bytes.
+func poolScrypt(password, salt []byte, n, r, p, keyLen int) ([]byte, error) {
+	var key []byte
+	var err error
+	if poolErr := DefaultHashPool.Do(func() {
+		key, err = scrypt.Key(password, salt, n, r, p, keyLen)
+	}); poolErr != nil {
+		return nil, poolErr
+	}
+	return key, err
+}
+
+// decodeAdaptedBase64 decodes the unpadded base64 used by PHC strings, including
+// passlib's variant that writes '.' instead of '+'.
+func decodeAdaptedBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.ReplaceAll(s, ".", "+"), "=")
+	return base64.RawStdEncoding.DecodeString(s)
+}
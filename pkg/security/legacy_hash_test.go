@@ -0,0 +1,119 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Vectors computed independently (Python hashlib, and the crypt_blowfish test suite for
+// bcrypt) in the exact format each system stores.
+var legacyHashVectors = []struct {
+	scheme, password, hash string
+}{
+	{"bcrypt", "U*U", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+	{"bcrypt", "U*U", "$2b$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+	{"bcrypt", "U*U", "$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+	{"scrypt", "correct horse battery staple",
+		"$scrypt$ln=10,r=8,p=1$AAECAwQFBgcICQoLDA0ODw$mp90zEQd5XGhjEv4WArVH4Z0XRSzkGWtJK2S/AXJlRU"},
+	{"pbkdf2-sha256", "correct horse battery staple",
+		"$pbkdf2-sha256$1000$AAECAwQFBgcICQoLDA0ODw$ppsXnjrdPB4KryJ6DrOqKqhkWrhv7PbKAMF1Eml8cZ4"},
+	{"pbkdf2-sha256", "correct horse battery staple",
+		"$pbkdf2-sha256$i=1000,l=32$AAECAwQFBgcICQoLDA0ODw$ppsXnjrdPB4KryJ6DrOqKqhkWrhv7PbKAMF1Eml8cZ4"},
+	{"pbkdf2-sha512", "correct horse battery staple",
+		"$pbkdf2-sha512$1000$AAECAwQFBgcICQoLDA0ODw$A6AmFIti7CKlYdoPiV9jf1d9llp37o27Wms8Zx8fwMJGCOigJzAthOW3Pg.XF5PnNiYnsQsIz1N5NtynrEm78w"},
+	{"pbkdf2-sha1", "correct horse battery staple",
+		"$pbkdf2$1000$AAECAwQFBgcICQoLDA0ODw$AOm/kOb/.YAZ3ZwSogYgNu9fO1g"},
+	{"django-pbkdf2-sha256", "correct horse battery staple",
+		"pbkdf2_sha256$1000$seasalt2024$eVE/oZISoCAklNifJeMrN1B7sUBT3nJUh5EUbllcLD0="},
+	{"django-pbkdf2-sha1", "correct horse battery staple",
+		"pbkdf2_sha1$1000$seasalt2024$ScxiuSJePoszs0of7/yxiEeGALY="},
+	{"django-scrypt", "correct horse battery staple",
+		"scrypt$16384$seasalt2024$8$1$hShBdDgh6YZ6QicHXG0XziZW2NLUH/ZbHQYaYJjcD3/cqO21DY4dZqaUssBXkyjKNBmTTOjQ+E4uA2+YrEjZRA=="},
+}
+
+func TestCompareLegacyHashes(t *testing.T) {
+	for _, v := range legacyHashVectors {
+		if got := HashScheme(v.hash); got != v.scheme {
+			t.Errorf("HashScheme(%q) = %q, want %q", v.hash, got, v.scheme)
+		}
+		if !NeedsRehash(v.hash) {
+			t.Errorf("%s: NeedsRehash = false", v.scheme)
+		}
+
+		ok, err := ComparePassword(v.password, v.hash)
+		if err != nil || !ok {
+			t.Errorf("%s: correct password rejected: %v", v.hash, err)
+		}
+		if ok, _ := ComparePassword(v.password+"!", v.hash); ok {
+			t.Errorf("%s: wrong password accepted", v.hash)
+		}
+	}
+}
+
+func TestCompareDjangoBcrypt(t *testing.T) {
+	password := "correct horse battery staple"
+
+	plain, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bcrypt_sha256 feeds bcrypt the hex SHA-256 of the password
+	sum := sha256.Sum256([]byte(password))
+	prehashed, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(sum[:])), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for scheme, hash := range map[string]string{
+		"django-bcrypt":        "bcrypt$" + string(plain),
+		"django-bcrypt-sha256": "bcrypt_sha256$" + string(prehashed),
+	} {
+		if got := HashScheme(hash); got != scheme {
+			t.Errorf("HashScheme(%q) = %q, want %q", hash, got, scheme)
+		}
+		if ok, err := ComparePassword(password, hash); err != nil || !ok {
+			t.Errorf("%s: correct password rejected: %v", scheme, err)
+		}
+		if ok, _ := ComparePassword("wrong", hash); ok {
+			t.Errorf("%s: wrong password accepted", scheme)
+		}
+	}
+}
+
+func TestCompareDjangoArgon2(t *testing.T) {
+	native, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := "argon2" + native
+	if got := HashScheme(hash); got != "django-argon2" {
+		t.Fatalf("HashScheme = %q", got)
+	}
+	if ok, err := ComparePassword("correct horse battery staple", hash); err != nil || !ok {
+		t.Fatalf("correct password rejected: %v", err)
+	}
+	if ok, _ := ComparePassword("wrong", hash); ok {
+		t.Fatal("wrong password accepted")
+	}
+	if _, err := ComparePassword("x", "argon2$argon2i$v=19$m=512,t=2,p=2$c2FsdA$aGFzaA"); err == nil {
+		t.Fatal("argon2i hash accepted")
+	}
+}
+
+func TestCompareMalformedLegacyHashes(t *testing.T) {
+	for _, hash := range []string{
+		"scrypt$16384$salt$8$hash",
+		"scrypt$salt$16384$8$1$aGFzaA==",
+		"pbkdf2_sha256$many$salt$aGFzaA==",
+		"$pbkdf2-sha256$1000$salt",
+		"md5$salt$hash",
+	} {
+		if ok, err := ComparePassword("x", hash); ok || err == nil {
+			t.Errorf("ComparePassword(%q) = %v, %v, want an error", hash, ok, err)
+		}
+	}
+}
@@ -2,13 +2,47 @@ package security
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base32"
 	"fmt"
 	"net/url"
+	"strings"
+	"time"
 
-	"github.com/pquerna/otp/totp"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
 )
 
+// --- TOTP Configuration ---
+// Changing Period, Digits or Algorithm invalidates authenticators that are already enrolled,
+// since those values are baked into the otpauth:// URI the user scanned.
+type TOTPConfig struct {
+	Period    uint          // Seconds per time step
+	Skew      uint          // Steps accepted before/after the current one to absorb clock drift
+	Digits    otp.Digits    // 6 or 8
+	Algorithm otp.Algorithm // SHA1, SHA256 or SHA512
+}
+
+var DefaultTOTPConfig = TOTPConfig{
+	Period:    30,
+	Skew:      1,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1, // Most compatible with Google Authenticator
+}
+
+// ParseTOTPAlgorithm converts "SHA1", "SHA256" or "SHA512" into an otp.Algorithm.
+func ParseTOTPAlgorithm(name string) (otp.Algorithm, error) {
+	switch strings.ToUpper(name) {
+	case "SHA1":
+		return otp.AlgorithmSHA1, nil
+	case "SHA256":
+		return otp.AlgorithmSHA256, nil
+	case "SHA512":
+		return otp.AlgorithmSHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported totp algorithm: %q", name)
+}
+
 // GenerateMFASecret generates a random Base32 string (compatible with TOTP secrets).
 func GenerateMFASecret() (string, error) {
 	secret := make([]byte, 20)
@@ -20,13 +54,83 @@ func GenerateMFASecret() (string, error) {
 }
 
 // GetMFAQRCodeURI returns the URI for QR code generation (compatible with Google Authenticator).
+// Period, digits and algorithm reflect DefaultTOTPConfig so the authenticator generates matching codes.
 func GetMFAQRCodeURI(email, secret string) string {
 	issuer := "SentinelAuth"
-	return fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s",
-		url.PathEscape(issuer), url.PathEscape(email), secret, url.QueryEscape(issuer))
+	cfg := DefaultTOTPConfig
+	return fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s&algorithm=%s&digits=%d&period=%d",
+		url.PathEscape(issuer), url.PathEscape(email), secret, url.QueryEscape(issuer),
+		cfg.Algorithm.String(), cfg.Digits.Length(), cfg.Period)
+}
+
+// MatchMFACode checks the code against every time step within the configured skew
+// and returns the Unix time at which the matched step started. Callers persist it to
+// reject replays: a code is only acceptable if its step started after the last accepted
+// one. Wall-clock time rather than the step index keeps that ordering intact when the
+// period changes or a new secret is enrolled.
+func MatchMFACode(code, secret string, at time.Time) (int64, bool) {
+	// An empty secret decodes to an empty key, for which codes are trivially computable
+	if secret == "" {
+		return 0, false
+	}
+
+	cfg := DefaultTOTPConfig
+	opts := hotp.ValidateOpts{Digits: cfg.Digits, Algorithm: cfg.Algorithm}
+
+	current := at.Unix() / int64(cfg.Period)
+	for step := current - int64(cfg.Skew); step <= current+int64(cfg.Skew); step++ {
+		if step < 0 {
+			continue
+		}
+		expected, err := hotp.GenerateCodeCustom(secret, uint64(step), opts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step * int64(cfg.Period), true
+		}
+	}
+
+	return 0, false
 }
 
-// VerifyMFACode checks if the provided 6-digit code is valid for the given secret.
+// VerifyMFACode checks if the provided code is valid for the given secret right now.
+// It does not protect against replays; prefer MatchMFACode when a last-step store is available.
 func VerifyMFACode(code, secret string) bool {
-	return totp.Validate(code, secret)
-}
\ No newline at end of file
+	_, ok := MatchMFACode(code, secret, time.Now())
+	return ok
+}
+
+// GenerateRecoveryCodes returns n one-time recovery codes formatted as "xxxxx-xxxxx".
+// Each code carries 50 bits of entropy from crypto/rand.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw))[:10]
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips separators and case so "ABCDE-FGHIJ" and "abcdefghij" match.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// IsTOTPCode reports whether the input looks like a TOTP code rather than a recovery code.
+func IsTOTPCode(code string) bool {
+	if len(code) != DefaultTOTPConfig.Digits.Length() {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
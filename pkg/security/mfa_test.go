@@ -0,0 +1,100 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestMatchMFACodeReturnsStepStartTime(t *testing.T) {
+	secret, err := GenerateMFASecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Unix(1_700_000_015, 0)
+	code, err := totp.GenerateCodeCustom(secret, at, totp.ValidateOpts{
+		Period:    DefaultTOTPConfig.Period,
+		Digits:    DefaultTOTPConfig.Digits,
+		Algorithm: DefaultTOTPConfig.Algorithm,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stepTime, ok := MatchMFACode(code, secret, at)
+	if !ok {
+		t.Fatal("current code rejected")
+	}
+	period := int64(DefaultTOTPConfig.Period)
+	if want := at.Unix() / period * period; stepTime != want {
+		t.Fatalf("step time = %d, want %d", stepTime, want)
+	}
+
+	// Doubling the period must not make later codes look older than earlier ones
+	defer func(cfg TOTPConfig) { DefaultTOTPConfig = cfg }(DefaultTOTPConfig)
+	DefaultTOTPConfig.Period *= 2
+
+	later := at.Add(2 * time.Duration(DefaultTOTPConfig.Period) * time.Second)
+	code, err = totp.GenerateCodeCustom(secret, later, totp.ValidateOpts{
+		Period:    DefaultTOTPConfig.Period,
+		Digits:    DefaultTOTPConfig.Digits,
+		Algorithm: DefaultTOTPConfig.Algorithm,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	laterStep, ok := MatchMFACode(code, secret, later)
+	if !ok {
+		t.Fatal("code under the new period rejected")
+	}
+	if laterStep <= stepTime {
+		t.Fatalf("step time went backwards after a period change: %d <= %d", laterStep, stepTime)
+	}
+}
+
+func TestMatchMFACodeRejectsEmptySecret(t *testing.T) {
+	at := time.Now()
+	code, err := totp.GenerateCodeCustom("", at, totp.ValidateOpts{
+		Period:    DefaultTOTPConfig.Period,
+		Digits:    DefaultTOTPConfig.Digits,
+		Algorithm: DefaultTOTPConfig.Algorithm,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := MatchMFACode(code, "", at); ok {
+		t.Fatal("code accepted for an empty secret")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Fatalf("malformed recovery code %q", code)
+		}
+		if IsTOTPCode(code) {
+			t.Fatalf("recovery code %q mistaken for a TOTP code", code)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+	}
+
+	if got := NormalizeRecoveryCode(" ABCDE-fghij "); got != "abcdefghij" {
+		t.Fatalf("NormalizeRecoveryCode = %q", got)
+	}
+	for code, want := range map[string]bool{"123456": true, "12345": false, "1234567": false, "12345a": false} {
+		if IsTOTPCode(code) != want {
+			t.Errorf("IsTOTPCode(%q) = %v, want %v", code, !want, want)
+		}
+	}
+}
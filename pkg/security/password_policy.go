@@ -0,0 +1,341 @@
+package security
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordViolation is a machine-readable code for a failed password rule.
+type PasswordViolation string
+
+const (
+	ViolationTooShort      PasswordViolation = "too_short"
+	ViolationTooLong       PasswordViolation = "too_long"
+	ViolationMissingLower  PasswordViolation = "missing_lowercase"
+	ViolationMissingUpper  PasswordViolation = "missing_uppercase"
+	ViolationMissingDigit  PasswordViolation = "missing_digit"
+	ViolationMissingSymbol PasswordViolation = "missing_symbol"
+	ViolationCommon        PasswordViolation = "common_password"
+	ViolationContainsEmail PasswordViolation = "contains_email"
+	ViolationTooWeak       PasswordViolation = "too_weak"
+	ViolationBreached      PasswordViolation = "breached_password"
+)
+
+// --- Password Policy ---
+// Lengths are counted in characters (runes). MaxLength bounds the input handed to Argon2id.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	MinScore      int // Minimum Strength score, 0 (guessable) to 4 (very strong)
+
+	// Breaches is an optional local HIBP corpus. A password seen at least BreachThreshold
+	// times is compromised; it fails Check only if RejectBreached is set, otherwise callers
+	// are expected to flag it via Breached.
+	Breaches        *BreachChecker
+	BreachThreshold int
+	RejectBreached  bool
+
+	denylist map[string]struct{}
+}
+
+// DefaultPasswordPolicy follows NIST SP 800-63B: length and strength over composition rules.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength: 8,
+	MaxLength: 128,
+	MinScore:  2,
+}
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// commonPasswords is denied by every policy, on top of any list loaded with LoadDenylist.
+var commonPasswords = parseDenylist(bufio.NewScanner(strings.NewReader(commonPasswordsFile)))
+
+// LoadDenylist reads common passwords from a file, one per line. Blank lines and lines
+// starting with '#' are ignored; matching is case-insensitive. The built-in list of the
+// most common passwords stays in force.
+func (p *PasswordPolicy) LoadDenylist(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open password denylist: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	denylist := parseDenylist(scanner)
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read password denylist: %w", err)
+	}
+
+	p.denylist = denylist
+	return nil
+}
+
+func parseDenylist(scanner *bufio.Scanner) map[string]struct{} {
+	denylist := make(map[string]struct{})
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	return denylist
+}
+
+// Check validates a candidate password for the account identified by email and returns
+// every failed rule. An empty result means the password is acceptable.
+func (p *PasswordPolicy) Check(password, email string) []PasswordViolation {
+	var violations []PasswordViolation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, ViolationTooShort)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, ViolationTooLong)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, ViolationMissingLower)
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, ViolationMissingUpper)
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, ViolationMissingDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, ViolationMissingSymbol)
+	}
+
+	if p.isDenied(strings.ToLower(password)) {
+		violations = append(violations, ViolationCommon)
+	}
+
+	userInputs := emailInputs(email)
+	lowered := strings.ToLower(password)
+	for _, in := range userInputs {
+		if strings.Contains(lowered, in) {
+			violations = append(violations, ViolationContainsEmail)
+			break
+		}
+	}
+
+	if p.Strength(password, userInputs...) < p.MinScore {
+		violations = append(violations, ViolationTooWeak)
+	}
+
+	if p.RejectBreached {
+		if _, breached := p.Breached(password); breached {
+			violations = append(violations, ViolationBreached)
+		}
+	}
+
+	return violations
+}
+
+// Breached reports how often the password appears in the breach corpus and whether that
+// reaches BreachThreshold. Without a corpus, or if it cannot be read, the password passes:
+// an unavailable corpus must not lock users out of registration.
+func (p *PasswordPolicy) Breached(password string) (int, bool) {
+	if p.Breaches == nil {
+		return 0, false
+	}
+
+	count, err := p.Breaches.Occurrences(password)
+	if err != nil {
+		return 0, false
+	}
+
+	threshold := p.BreachThreshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return count, count >= threshold
+}
+
+// Strength estimates how hard the password is to guess, on the zxcvbn scale:
+// 0 (< 10^3 guesses) to 4 (>= 10^10 guesses). Repeats, sequences, keyboard walks,
+// denylisted words (including leetspeak variants such as "P@ssw0rd!") and any of
+// userInputs contribute almost nothing.
+func (p *PasswordPolicy) Strength(password string, userInputs ...string) int {
+	bits := p.entropy(password, userInputs)
+
+	switch {
+	case bits < 10: // ~10^3 guesses
+		return 0
+	case bits < 20: // ~10^6
+		return 1
+	case bits < 27: // ~10^8
+		return 2
+	case bits < 33: // ~10^10
+		return 3
+	}
+	return 4
+}
+
+// entropy returns a rough log2 of the number of guesses an attacker needs.
+func (p *PasswordPolicy) entropy(password string, userInputs []string) float64 {
+	s := strings.ToLower(password)
+	bits := 0.0
+
+	// Personal data is one of the first things an attacker tries
+	for _, in := range userInputs {
+		if strings.Contains(s, in) {
+			s = strings.ReplaceAll(s, in, "")
+			bits++
+		}
+	}
+
+	// A common password with decorations costs as much as picking it from the list
+	runes := []rune(s)
+	start, end := 0, len(runes)
+	for start < end && !unicode.IsLetter(runes[start]) {
+		start++
+	}
+	for end > start && !unicode.IsLetter(runes[end-1]) {
+		end--
+	}
+	if core := string(runes[start:end]); core != "" && (p.isDenied(core) || p.isDenied(unleet(core))) {
+		bits += math.Log2(float64(len(commonPasswords) + len(p.denylist) + 1))
+		runes = append(runes[:start:start], runes[end:]...)
+	}
+
+	perChar := math.Log2(float64(charsetSize(password)))
+	prev := rune(-1)
+	for _, r := range runes {
+		if prev >= 0 && predictable(prev, r) {
+			bits++
+		} else {
+			bits += perChar
+		}
+		prev = r
+	}
+
+	return bits
+}
+
+func (p *PasswordPolicy) isDenied(password string) bool {
+	if _, ok := commonPasswords[password]; ok {
+		return true
+	}
+	_, ok := p.denylist[password]
+	return ok
+}
+
+// emailInputs returns the lowercased email and its local part, the parts of an address
+// a user is likely to embed in a password. Parts shorter than 3 characters are skipped.
+func emailInputs(email string) []string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	var inputs []string
+	if len(email) >= 3 {
+		inputs = append(inputs, email)
+	}
+	if local, _, ok := strings.Cut(email, "@"); ok && len(local) >= 3 {
+		inputs = append(inputs, local)
+	}
+	return inputs
+}
+
+// charsetSize estimates the alphabet an attacker must search from the classes in use.
+func charsetSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+// keyboardRows are scanned for keyboard walks such as "qwerty" or "asdf".
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// predictable reports whether r follows prev as a repeat, an alphabetic or numeric
+// sequence, or a step along a keyboard row.
+func predictable(prev, r rune) bool {
+	if r == prev || r == prev+1 || r == prev-1 {
+		return true
+	}
+	for _, row := range keyboardRows {
+		i, j := strings.IndexRune(row, prev), strings.IndexRune(row, r)
+		if i >= 0 && j >= 0 && (i-j == 1 || j-i == 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// unleet undoes common character substitutions, rune for rune.
+func unleet(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '4', '@':
+			return 'a'
+		case '3':
+			return 'e'
+		case '1', '!', '|':
+			return 'i'
+		case '0':
+			return 'o'
+		case '5', '$':
+			return 's'
+		case '7':
+			return 't'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,82 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	policy := DefaultPasswordPolicy
+
+	tests := []struct {
+		password string
+		max      int // highest acceptable score
+		min      int // lowest acceptable score
+	}{
+		// Common passwords, bare or decorated, never reach the default MinScore
+		{"password", 1, 0},
+		{"password1", 1, 0},
+		{"iloveyou", 1, 0},
+		{"sunshine", 1, 0},
+		{"monkey123", 1, 0},
+		{"letmein1", 1, 0},
+		{"P@ssw0rd!", 1, 0},
+		{"12345678", 1, 0},
+		{"qwertyuiop", 1, 0},
+		// Repeats, sequences and keyboard walks
+		{"aaaaaaaa", 1, 0},
+		{"abcdefgh", 1, 0},
+		{"asdfghjkl;", 1, 0},
+		// Random strings and passphrases
+		{"xK9#mQ2$vL", 4, 4},
+		{"Gx7!kP2q", 4, 3},
+		{"correct horse battery staple", 4, 4},
+		{"zebra-kettle-orbit", 4, 4},
+	}
+	for _, tt := range tests {
+		if got := policy.Strength(tt.password); got < tt.min || got > tt.max {
+			t.Errorf("Strength(%q) = %d, want %d..%d", tt.password, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := DefaultPasswordPolicy
+
+	tests := []struct {
+		password, email string
+		want            []PasswordViolation
+	}{
+		{"zebra-kettle-orbit", "alice@example.com", nil},
+		{"short", "", []PasswordViolation{ViolationTooShort, ViolationTooWeak}},
+		{"sunshine", "", []PasswordViolation{ViolationCommon, ViolationTooWeak}},
+		{"Password", "", []PasswordViolation{ViolationCommon, ViolationTooWeak}},
+		{"alice-kettle-orbit", "alice@example.com", []PasswordViolation{ViolationContainsEmail}},
+	}
+	for _, tt := range tests {
+		if got := policy.Check(tt.password, tt.email); !slices.Equal(got, tt.want) {
+			t.Errorf("Check(%q, %q) = %v, want %v", tt.password, tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyDenylistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte("# site names\nSentinelAuth2024\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := DefaultPasswordPolicy
+	if err := policy.LoadDenylist(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file extends the built-in list rather than replacing it
+	for _, password := range []string{"sentinelauth2024", "password"} {
+		if !slices.Contains(policy.Check(password, ""), ViolationCommon) {
+			t.Errorf("%q not denied", password)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// --- Password Pepper ---
+// A pepper is a server-side secret kept out of the database. Passwords are mixed with it
+// through HMAC-SHA256 before Argon2id, so a leaked users table alone cannot be cracked.
+// The ID of the pepper is stored in the hash parameters ("m=..,t=..,p=..,k=<id>"), which
+// lets old hashes be verified after a rotation and upgraded by NeedsRehash on login.
+type PepperConfig struct {
+	CurrentID string            // Pepper used for new hashes; empty disables peppering
+	Keys      map[string][]byte // Every pepper that may still appear in stored hashes
+}
+
+var DefaultPepper PepperConfig
+
+// pepperIDPattern keeps IDs safe to embed in the PHC parameter list.
+var pepperIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// ParsePeppers reads "<id>:<base64 key>[,<id>:<base64 key>...]". The first entry is the
+// current pepper; the others are kept to verify hashes made before a rotation.
+func ParsePeppers(s string) (PepperConfig, error) {
+	cfg := PepperConfig{Keys: make(map[string][]byte)}
+
+	for i, entry := range strings.Split(s, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !pepperIDPattern.MatchString(id) {
+			return PepperConfig{}, fmt.Errorf("invalid pepper entry %d: expected <id>:<base64 key>", i+1)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) < 32 {
+			return PepperConfig{}, fmt.Errorf("invalid pepper %q: key must be at least 32 bytes of base64", id)
+		}
+		if _, dup := cfg.Keys[id]; dup {
+			return PepperConfig{}, fmt.Errorf("duplicate pepper id %q", id)
+		}
+
+		cfg.Keys[id] = key
+		if i == 0 {
+			cfg.CurrentID = id
+		}
+	}
+
+	return cfg, nil
+}
+
+// applyPepper returns the Argon2id input for password under the given pepper ID.
+// An empty ID means the hash was made without a pepper.
+func applyPepper(password, pepperID string) ([]byte, error) {
+	if pepperID == "" {
+		return []byte(password), nil
+	}
+
+	key, ok := DefaultPepper.Keys[pepperID]
+	if !ok {
+		return nil, fmt.Errorf("unknown pepper id %q", pepperID)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,101 @@
+package security
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// usePeppers installs the given PASSWORD_PEPPERS value and cheap Argon2id costs for one test.
+func usePeppers(t *testing.T, s string) {
+	t.Helper()
+	cfg, err := ParsePeppers(s)
+	if err != nil {
+		t.Fatalf("ParsePeppers(%q): %v", s, err)
+	}
+
+	pepper, params := DefaultPepper, DefaultParams
+	t.Cleanup(func() { DefaultPepper, DefaultParams = pepper, params })
+	DefaultPepper = cfg
+	DefaultParams.Memory, DefaultParams.Iterations, DefaultParams.Parallelism = 1024, 1, 1
+}
+
+func testPepperKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestPepperRotation(t *testing.T) {
+	usePeppers(t, "v1:"+testPepperKey('a'))
+	old, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(old, ",k=v1$") {
+		t.Fatalf("hash %q does not name its pepper", old)
+	}
+
+	// v2 becomes current; v1 stays listed so existing hashes still verify
+	DefaultPepper, _ = ParsePeppers("v2:" + testPepperKey('b') + ",v1:" + testPepperKey('a'))
+
+	if ok, err := ComparePassword("correct horse battery staple", old); err != nil || !ok {
+		t.Fatalf("old-pepper hash after rotation: %v, %v", ok, err)
+	}
+	if ok, _ := ComparePassword("wrong password", old); ok {
+		t.Fatal("wrong password accepted")
+	}
+	if !NeedsRehash(old) {
+		t.Fatal("old-pepper hash not flagged for rehash")
+	}
+
+	fresh, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fresh, ",k=v2$") || NeedsRehash(fresh) {
+		t.Fatalf("new hash %q is not on the current pepper", fresh)
+	}
+
+	// The pepper is part of the input: the same hash under another key does not verify
+	DefaultPepper.Keys["v1"] = []byte(strings.Repeat("c", 32))
+	if ok, _ := ComparePassword("correct horse battery staple", old); ok {
+		t.Fatal("hash verified under a different pepper key")
+	}
+}
+
+func TestPepperUnknownID(t *testing.T) {
+	usePeppers(t, "v1:"+testPepperKey('a'))
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pepper was dropped from the configuration before its hashes were upgraded
+	DefaultPepper, _ = ParsePeppers("v2:" + testPepperKey('b'))
+	if ok, err := ComparePassword("correct horse battery staple", hash); err == nil || ok {
+		t.Fatalf("unknown pepper id: %v, %v; want an error", ok, err)
+	}
+}
+
+func TestParsePeppers(t *testing.T) {
+	cfg, err := ParsePeppers(" v2:" + testPepperKey('b') + " , v1:" + testPepperKey('a'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CurrentID != "v2" || len(cfg.Keys) != 2 {
+		t.Fatalf("config = %+v", cfg)
+	}
+
+	for name, s := range map[string]string{
+		"missing key":  "v1",
+		"empty id":     ":" + testPepperKey('a'),
+		"invalid id":   "v$1:" + testPepperKey('a'),
+		"not base64":   "v1:not base64!",
+		"short key":    "v1:" + base64.StdEncoding.EncodeToString([]byte("too short")),
+		"duplicate id": "v1:" + testPepperKey('a') + ",v1:" + testPepperKey('b'),
+		"empty entry":  "v1:" + testPepperKey('a') + ",",
+	} {
+		if _, err := ParsePeppers(s); err == nil {
+			t.Errorf("%s: %q accepted", name, s)
+		}
+	}
+}
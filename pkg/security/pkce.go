@@ -0,0 +1,32 @@
+package security
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+// --- PKCE (RFC 7636) ---
+// Only the S256 method is supported: the client sends BASE64URL(SHA-256(verifier)) with
+// the authorization request and the verifier itself when redeeming the code.
+var (
+	pkceVerifierPattern  = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+	pkceChallengePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+)
+
+// ValidPKCEChallenge reports whether challenge has the shape of an S256 challenge.
+func ValidPKCEChallenge(challenge string) bool {
+	return pkceChallengePattern.MatchString(challenge)
+}
+
+// VerifyPKCE checks a code verifier against the S256 challenge of the authorization request.
+func VerifyPKCE(verifier, challenge string) bool {
+	if !pkceVerifierPattern.MatchString(verifier) {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
@@ -0,0 +1,62 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// RFC 7636 Appendix B
+const (
+	rfcVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfcChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	if !ValidPKCEChallenge(rfcChallenge) {
+		t.Fatal("RFC 7636 challenge rejected as malformed")
+	}
+	if !VerifyPKCE(rfcVerifier, rfcChallenge) {
+		t.Fatal("RFC 7636 verifier does not match its challenge")
+	}
+
+	cases := map[string]string{
+		"other verifier": "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXK",
+		"plain method":   rfcChallenge, // verifier == challenge
+		"too short":      rfcVerifier[:42],
+		"too long":       strings.Repeat("a", 129),
+		"invalid char":   rfcVerifier[:42] + "+",
+		"empty":          "",
+		"trailing space": rfcVerifier + " ",
+	}
+	for name, verifier := range cases {
+		if VerifyPKCE(verifier, rfcChallenge) {
+			t.Errorf("%s: verifier %q accepted", name, verifier)
+		}
+	}
+}
+
+func TestVerifyPKCELengthBounds(t *testing.T) {
+	for _, n := range []int{43, 128} {
+		verifier := strings.Repeat("~", n)
+		sum := sha256.Sum256([]byte(verifier))
+		if !VerifyPKCE(verifier, base64.RawURLEncoding.EncodeToString(sum[:])) {
+			t.Errorf("%d-character verifier rejected", n)
+		}
+	}
+}
+
+func TestValidPKCEChallenge(t *testing.T) {
+	for _, challenge := range []string{
+		"",
+		rfcChallenge[:42],
+		rfcChallenge + "A",
+		rfcChallenge + "=",
+		strings.Replace(rfcChallenge, "-", "+", 1),
+	} {
+		if ValidPKCEChallenge(challenge) {
+			t.Errorf("%q accepted as an S256 challenge", challenge)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package security
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -33,98 +36,253 @@ var DefaultParams = HashParams{
 
 // HashPassword generates an Argon2id hash from a plaintext password.
 // Returns a string in the standard encoded format: $argon2id$v=19$m=...,t=...,p=...$salt$hash
+// When a pepper is configured its ID is appended to the parameters: m=...,t=...,p=...,k=<id>
 func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+
+	input, err := applyPepper(password, DefaultPepper.CurrentID)
+	if err != nil {
+		return "", err
+	}
+
 	salt := make([]byte, DefaultParams.SaltLength)
 	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
 
-	hash := argon2.IDKey(
-		[]byte(password),
-		salt,
-		DefaultParams.Iterations,
-		DefaultParams.Memory,
-		DefaultParams.Parallelism,
-		DefaultParams.KeyLength,
-	)
+	var hash []byte
+	err = DefaultHashPool.Do(func() {
+		hash = argon2.IDKey(
+			input,
+			salt,
+			DefaultParams.Iterations,
+			DefaultParams.Memory,
+			DefaultParams.Parallelism,
+			DefaultParams.KeyLength,
+		)
+	})
+	if err != nil {
+		return "", err
+	}
 
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
 
-	encoded := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
-		argon2.Version, DefaultParams.Memory, DefaultParams.Iterations, DefaultParams.Parallelism, b64Salt, b64Hash)
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", DefaultParams.Memory, DefaultParams.Iterations, DefaultParams.Parallelism)
+	if DefaultPepper.CurrentID != "" {
+		params += ",k=" + DefaultPepper.CurrentID
+	}
+
+	encoded := fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params, b64Salt, b64Hash)
 
 	return encoded, nil
 }
 
+// argon2Hash is a decoded "$argon2id$" string.
+type argon2Hash struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	pepperID    string
+	salt        []byte
+	hash        []byte
+}
+
+// parseArgon2Hash decodes the PHC string written by HashPassword.
+func parseArgon2Hash(encodedHash string) (*argon2Hash, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errors.New("invalid hash format")
+	}
+
+	h := &argon2Hash{}
+	params, pepperID, _ := strings.Cut(parts[3], ",k=")
+	if _, err := fmt.Sscanf(params, "m=%d,t=%d,p=%d", &h.memory, &h.iterations, &h.parallelism); err != nil {
+		return nil, err
+	}
+	h.pepperID = pepperID
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, err
+	}
+	if h.hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
 // ComparePassword checks if a hash matches a plaintext password.
 // It uses constant-time comparison to prevent timing attacks.
+// Hashes imported from legacy systems (bcrypt, scrypt, PBKDF2, Django) are dispatched on their prefix.
 func ComparePassword(password, encodedHash string) (bool, error) {
-	parts := strings.Split(encodedHash, "$")
-	if len(parts) != 6 {
-		return false, errors.New("invalid hash format")
+	if !strings.HasPrefix(encodedHash, "$argon2id$") {
+		return compareLegacyPassword(password, encodedHash)
 	}
 
-	var memory, iterations uint32
-	var parallelism uint8
-	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism)
+	h, err := parseArgon2Hash(encodedHash)
 	if err != nil {
 		return false, err
 	}
 
-	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	input, err := applyPepper(password, h.pepperID)
 	if err != nil {
 		return false, err
 	}
 
-	decodedHash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	var comparisonHash []byte
+	err = DefaultHashPool.Do(func() {
+		comparisonHash = argon2.IDKey(input, h.salt, h.iterations, h.memory, h.parallelism, uint32(len(h.hash)))
+	})
 	if err != nil {
 		return false, err
 	}
 
-	keyLen := uint32(len(decodedHash))
-	comparisonHash := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, keyLen)
-
-	if subtle.ConstantTimeCompare(decodedHash, comparisonHash) == 1 {
+	if subtle.ConstantTimeCompare(h.hash, comparisonHash) == 1 {
 		return true, nil
 	}
 
 	return false, nil
 }
 
+// NeedsRehash reports whether an encoded hash was produced with parameters other than
+// DefaultParams (memory, iterations, parallelism or key length) or another pepper than
+// the current one, or is not Argon2id at all.
+// Callers re-hash the password after a successful ComparePassword to roll out new costs.
+func NeedsRehash(encodedHash string) bool {
+	if HashScheme(encodedHash) != "argon2id" {
+		return true
+	}
+
+	h, err := parseArgon2Hash(encodedHash)
+	if err != nil {
+		return true
+	}
+
+	return h.memory != DefaultParams.Memory ||
+		h.iterations != DefaultParams.Iterations ||
+		h.parallelism != DefaultParams.Parallelism ||
+		uint32(len(h.hash)) != DefaultParams.KeyLength ||
+		h.pepperID != DefaultPepper.CurrentID
+}
+
+// dummyHash is an Argon2id hash with the current DefaultParams and pepper but random
+// contents, built on first use. No real hash is computed, so building it needs no pool slot.
+var dummyHash = sync.OnceValue(func() string {
+	salt := make([]byte, DefaultParams.SaltLength)
+	key := make([]byte, DefaultParams.KeyLength)
+	_, _ = rand.Read(salt)
+	_, _ = rand.Read(key)
+
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", DefaultParams.Memory, DefaultParams.Iterations, DefaultParams.Parallelism)
+	if DefaultPepper.CurrentID != "" {
+		params += ",k=" + DefaultPepper.CurrentID
+	}
+
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+})
+
+// DummyComparePassword burns the same CPU and memory as ComparePassword against a real
+// Argon2id hash. Call it when the account does not exist so response times do not reveal it.
+// It fails with ErrHashPoolBusy exactly when a real comparison would.
+func DummyComparePassword(password string) error {
+	_, err := ComparePassword(password, dummyHash())
+	return err
+}
+
+// --- Opaque Tokens ---
+
+// GenerateOpaqueToken returns a URL-safe random string with 256 bits of entropy.
+// Used for refresh, verification and other single-use tokens that are looked up server-side.
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// opaqueTokenLength is the length of a GenerateOpaqueToken string: 32 bytes, unpadded base64url.
+const opaqueTokenLength = 43
+
+// IsOpaqueToken reports whether s has the shape of a GenerateOpaqueToken string. Tokens
+// presented by clients are checked before being looked up, so arbitrary input never
+// reaches storage keys.
+func IsOpaqueToken(s string) bool {
+	if len(s) != opaqueTokenLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// HashOpaqueToken returns the SHA-256 of a token in hex. Tokens stored by their hash
+// cannot be redeemed by someone who only gets read access to the store.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // --- JWT Claims & Logic ---
 
+// TokenIssuer is the "iss" claim of access tokens and the issuer announced by OpenID
+// discovery. Resource servers that use discovery expect it to be the public base URL;
+// the server sets it from PUBLIC_URL at startup.
+var TokenIssuer = "sentinel-auth"
+
+// Claims of an access token. ClientID and Scope are only set on tokens issued to an
+// OAuth client; first-party sessions leave them empty.
 type Claims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID   string `json:"user_id"`
+	Role     string `json:"role"`
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateAccessToken creates a new JWT signed with a secret key.
-func GenerateAccessToken(userID, role, secret string, duration time.Duration) (string, error) {
+// GenerateAccessToken creates a new JWT signed with the current key of the keyring.
+// The token carries the key's ID in its "kid" header and a unique "jti", which lets a
+// single token be revoked.
+func GenerateAccessToken(userID, role string, keys *Keyring, duration time.Duration) (string, error) {
+	return GenerateClientAccessToken(userID, role, "", "", keys, duration)
+}
+
+// GenerateClientAccessToken creates an access token bound to an OAuth client and scope.
+func GenerateClientAccessToken(userID, role, clientID, scope string, keys *Keyring, duration time.Duration) (string, error) {
+	jti, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
 	claims := Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:   userID,
+		Role:     role,
+		ClientID: clientID,
+		Scope:    scope,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti[:22], // 128 bits
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "sentinel-auth",
+			Issuer:    TokenIssuer,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return keys.sign(claims)
 }
 
-// ValidateToken parses and validates a JWT string.
-func ValidateToken(tokenString, secret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
+// ValidateToken parses and validates a JWT string, picking the verification key by kid.
+func ValidateToken(tokenString string, keys *Keyring) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.keyfunc)
 
 	if err != nil {
 		return nil, err
@@ -135,4 +293,4 @@ func ValidateToken(tokenString, secret string) (*Claims, error) {
 	}
 
 	return nil, errors.New("invalid token")
-}
\ No newline at end of file
+}
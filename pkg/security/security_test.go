@@ -0,0 +1,26 @@
+package security
+
+import "testing"
+
+func TestIsOpaqueToken(t *testing.T) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsOpaqueToken(token) {
+		t.Fatalf("generated token %q rejected", token)
+	}
+
+	for _, s := range []string{
+		"",
+		token[:42],
+		token + "A",
+		"used:" + token[5:],
+		token[:42] + "=",
+		token[:42] + "+",
+	} {
+		if IsOpaqueToken(s) {
+			t.Errorf("IsOpaqueToken(%q) = true", s)
+		}
+	}
+}
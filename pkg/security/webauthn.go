@@ -0,0 +1,246 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var (
+	// ErrUnsupportedAttestation is returned when a credential uses an attestation format we do not accept.
+	ErrUnsupportedAttestation = errors.New("unsupported attestation format")
+	// ErrPasskeyCloneWarning is returned when the signature counter went backwards, which hints at a cloned authenticator.
+	ErrPasskeyCloneWarning = errors.New("authenticator sign counter did not increase")
+)
+
+// acceptedAttestationFormats lists the attestation statement formats accepted at registration.
+// "none" is what browsers send by default; "packed" covers security keys with self or x5c attestation.
+var acceptedAttestationFormats = map[string]bool{
+	"none":   true,
+	"packed": true,
+}
+
+// PasskeyCredential is the persisted form of a WebAuthn public key credential.
+type PasskeyCredential struct {
+	ID              []byte
+	PublicKey       []byte // COSE-encoded credential public key
+	AttestationType string
+	AAGUID          []byte
+	SignCount       uint32
+	Transports      []string
+}
+
+// PasskeyUser is the account a ceremony is performed for.
+// ID is the WebAuthn user handle and must never change for the account.
+type PasskeyUser struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+	Credentials []PasskeyCredential
+}
+
+func (u *PasskeyUser) WebAuthnID() []byte          { return u.ID }
+func (u *PasskeyUser) WebAuthnName() string        { return u.Name }
+func (u *PasskeyUser) WebAuthnDisplayName() string { return u.DisplayName }
+func (u *PasskeyUser) WebAuthnIcon() string        { return "" }
+
+func (u *PasskeyUser) WebAuthnCredentials() []webauthn.Credential {
+	creds := make([]webauthn.Credential, len(u.Credentials))
+	for i, c := range u.Credentials {
+		transports := make([]protocol.AuthenticatorTransport, len(c.Transports))
+		for j, t := range c.Transports {
+			transports[j] = protocol.AuthenticatorTransport(t)
+		}
+		creds[i] = webauthn.Credential{
+			ID:              c.ID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		}
+	}
+	return creds
+}
+
+// PasskeyUserLookup resolves the owner of a discoverable credential from its user handle.
+type PasskeyUserLookup func(userHandle []byte) (*PasskeyUser, error)
+
+// Passkeys runs the WebAuthn registration and assertion ceremonies for a single Relying Party.
+// Session data returned by the Begin* methods is opaque JSON that the caller must store
+// server-side and hand back to the matching Finish* method.
+type Passkeys struct {
+	w *webauthn.WebAuthn
+}
+
+// NewPasskeys creates a ceremony runner for the given Relying Party ID (usually the domain)
+// and the list of fully qualified origins allowed to talk to it.
+func NewPasskeys(rpID, rpDisplayName string, origins []string) (*Passkeys, error) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpDisplayName,
+		RPOrigins:     origins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid webauthn configuration: %w", err)
+	}
+	return &Passkeys{w: w}, nil
+}
+
+// BeginRegistration returns the PublicKeyCredentialCreationOptions for the browser.
+// Existing credentials are excluded so the same authenticator is not registered twice,
+// and a discoverable credential is preferred so the passkey can also be used passwordless.
+func (p *Passkeys) BeginRegistration(user *PasskeyUser) (interface{}, []byte, error) {
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.Credentials))
+	for _, c := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	options, session, err := p.w.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return options, sessionJSON, nil
+}
+
+// FinishRegistration verifies the attestation response and returns the new credential.
+func (p *Passkeys) FinishRegistration(user *PasskeyUser, sessionJSON, response []byte) (*PasskeyCredential, error) {
+	var session webauthn.SessionData
+	if err := json.Unmarshal(sessionJSON, &session); err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := p.w.CreateCredential(user, session, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	if !acceptedAttestationFormats[cred.AttestationType] {
+		return nil, ErrUnsupportedAttestation
+	}
+
+	return toPasskeyCredential(cred), nil
+}
+
+// BeginLogin returns assertion options restricted to the user's registered credentials.
+func (p *Passkeys) BeginLogin(user *PasskeyUser) (interface{}, []byte, error) {
+	options, session, err := p.w.BeginLogin(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return options, sessionJSON, nil
+}
+
+// BeginDiscoverableLogin returns assertion options for a passwordless login where the
+// authenticator chooses the credential. User verification is required since the passkey
+// replaces the password.
+func (p *Passkeys) BeginDiscoverableLogin() (interface{}, []byte, error) {
+	options, session, err := p.w.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return options, sessionJSON, nil
+}
+
+// FinishLogin verifies an assertion for a known user and returns the credential with its
+// updated sign counter.
+func (p *Passkeys) FinishLogin(user *PasskeyUser, sessionJSON, response []byte) (*PasskeyCredential, error) {
+	var session webauthn.SessionData
+	if err := json.Unmarshal(sessionJSON, &session); err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := p.w.ValidateLogin(user, session, parsed)
+	if err != nil {
+		return nil, err
+	}
+	if cred.Authenticator.CloneWarning {
+		return nil, ErrPasskeyCloneWarning
+	}
+
+	return toPasskeyCredential(cred), nil
+}
+
+// FinishDiscoverableLogin verifies a passwordless assertion. The owner is resolved from the
+// user handle returned by the authenticator.
+func (p *Passkeys) FinishDiscoverableLogin(lookup PasskeyUserLookup, sessionJSON, response []byte) (*PasskeyUser, *PasskeyCredential, error) {
+	var session webauthn.SessionData
+	if err := json.Unmarshal(sessionJSON, &session); err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var owner *PasskeyUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		u, err := lookup(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		owner = u
+		return u, nil
+	}
+
+	cred, err := p.w.ValidateDiscoverableLogin(handler, session, parsed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cred.Authenticator.CloneWarning {
+		return nil, nil, ErrPasskeyCloneWarning
+	}
+
+	return owner, toPasskeyCredential(cred), nil
+}
+
+// toPasskeyCredential converts the library credential into our persisted form.
+func toPasskeyCredential(c *webauthn.Credential) *PasskeyCredential {
+	transports := make([]string, len(c.Transport))
+	for i, t := range c.Transport {
+		transports[i] = string(t)
+	}
+	return &PasskeyCredential{
+		ID:              c.ID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		AAGUID:          c.Authenticator.AAGUID,
+		SignCount:       c.Authenticator.SignCount,
+		Transports:      transports,
+	}
+}
@@ -34,11 +34,22 @@ CREATE TABLE IF NOT EXISTS users (
     role_id UUID REFERENCES roles(id) ON DELETE RESTRICT,
     mfa_enabled BOOLEAN DEFAULT FALSE,
     mfa_secret TEXT, -- Encrypted TOTP secret
+    mfa_last_step BIGINT NOT NULL DEFAULT 0, -- Start (Unix seconds) of the last accepted TOTP step (replay protection)
+    email_verified BOOLEAN DEFAULT FALSE, -- Set once the verification token is redeemed
     last_login_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Upgrade databases created before email verification. Accounts that already exist are
+-- added as verified (they signed up when no verification was required); the default is
+-- then switched so new accounts start unverified. Both statements are no-ops on re-runs.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN DEFAULT TRUE;
+ALTER TABLE users ALTER COLUMN email_verified SET DEFAULT FALSE;
+
+-- Upgrade databases created before TOTP replay protection.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_last_step BIGINT NOT NULL DEFAULT 0;
+
 -- 6. Audit Logs Table (Immutable record of security events)
 CREATE TABLE IF NOT EXISTS audit_logs (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -50,13 +61,71 @@ CREATE TABLE IF NOT EXISTS audit_logs (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
--- 7. Indexes for Performance
+-- 7. MFA Recovery Codes (One-time backup codes, stored as Argon2id hashes)
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 8. WebAuthn Credentials (Passkeys / security keys)
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA UNIQUE NOT NULL,
+    public_key BYTEA NOT NULL, -- COSE-encoded public key
+    attestation_type VARCHAR(32) NOT NULL,
+    aaguid BYTEA,
+    sign_count BIGINT NOT NULL DEFAULT 0, -- Detects cloned authenticators
+    transports TEXT[],
+    name VARCHAR(100),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 9. Password History (previous Argon2id hashes, to refuse reuse)
+CREATE TABLE IF NOT EXISTS password_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 10. JWT Signing Keys (private keys encrypted with the key encryption key)
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id TEXT PRIMARY KEY, -- kid
+    algorithm VARCHAR(16) NOT NULL,
+    state VARCHAR(16) NOT NULL CHECK (state IN ('pending', 'active', 'retired', 'deleted')),
+    encrypted_key BYTEA, -- NULL once deleted
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    activated_at TIMESTAMP WITH TIME ZONE,
+    retired_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+-- 11. OAuth Clients (secrets stored as SHA-256; NULL for public clients)
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id TEXT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    secret_hash TEXT,
+    redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 12. Indexes for Performance
 CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_event_type ON audit_logs(event_type);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id) WHERE used_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history(user_id, created_at DESC);
+-- At most one key signs at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_active ON signing_keys((true)) WHERE state = 'active';
 
--- 8. Seed Default Data (Idempotent)
+-- 13. Seed Default Data (Idempotent)
 INSERT INTO roles (name) VALUES ('admin'), ('user') ON CONFLICT (name) DO NOTHING;
 
 INSERT INTO permissions (slug, description) VALUES 